@@ -0,0 +1,307 @@
+package muzzy
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// LevenshteinAutomaton accept strings within distance k from the query
+//
+// State of automaton is a row of distance matrix between the query and
+// prefix of a string, so strings sharing a prefix share states. Values of
+// matrix are limited by k+1, and state is dead when every value is great
+// than k. Distance of accepted string is the same as LevenshteinDistance (or
+// DamerauDistance) with bound k returns.
+type LevenshteinAutomaton struct {
+	query   []rune
+	k       int
+	damerau bool
+}
+
+// AutomatonState is a state of LevenshteinAutomaton after some prefix.
+type AutomatonState struct {
+	row []int
+	// Row and the last rune of the shorter prefix for transpositions.
+	prev []int
+	last rune
+}
+
+// NewLevenshteinAutomaton is a constructor of automaton accepting strings
+// within Levenshtein distance k from query. Use -1 as k to accept every
+// string.
+func NewLevenshteinAutomaton(query string, k int) *LevenshteinAutomaton {
+	return &LevenshteinAutomaton{query: []rune(query), k: k}
+}
+
+// NewDamerauAutomaton is a constructor of automaton accepting strings within
+// DamerauDistance k from query.
+func NewDamerauAutomaton(query string, k int) *LevenshteinAutomaton {
+	return &LevenshteinAutomaton{query: []rune(query), k: k, damerau: true}
+}
+
+// Start return state of empty prefix.
+func (a *LevenshteinAutomaton) Start() AutomatonState {
+	row := make([]int, len(a.query)+1)
+	for j := range row {
+		row[j] = a.limit(j)
+	}
+
+	return AutomatonState{row: row}
+}
+
+// Step return state after rune r.
+func (a *LevenshteinAutomaton) Step(state AutomatonState, r rune) AutomatonState {
+	row := state.row
+	next := make([]int, len(row))
+	next[0] = a.limit(row[0] + 1)
+
+	for j := 1; j < len(row); j++ {
+		cost := 1
+		if a.query[j-1] == r {
+			cost = 0
+		}
+
+		d := min(row[j-1]+cost, row[j]+1, next[j-1]+1)
+
+		if a.damerau && state.prev != nil && j > 1 && a.query[j-1] == state.last && a.query[j-2] == r {
+			d = min(d, state.prev[j-2]+1)
+		}
+
+		next[j] = a.limit(d)
+	}
+
+	return AutomatonState{row: next, prev: row, last: r}
+}
+
+// Distance of the prefix to the query or -1, if it is great than k.
+func (a *LevenshteinAutomaton) Distance(state AutomatonState) int {
+	d := state.row[len(state.row)-1]
+	if a.k >= 0 && d > a.k {
+		return -1
+	}
+
+	return d
+}
+
+// CanMatch return false if no string with the prefix is accepted.
+func (a *LevenshteinAutomaton) CanMatch(state AutomatonState) bool {
+	if a.k < 0 {
+		return true
+	}
+
+	for _, d := range state.row {
+		if d <= a.k {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Match return distance of s to the query or -1, if it is great than k.
+func (a *LevenshteinAutomaton) Match(s string) int {
+	state := a.Start()
+
+	for _, r := range s {
+		if !a.CanMatch(state) {
+			return -1
+		}
+
+		state = a.Step(state, r)
+	}
+
+	return a.Distance(state)
+}
+
+func (a *LevenshteinAutomaton) limit(d int) int {
+	if a.k >= 0 && d > a.k+1 {
+		return a.k + 1
+	}
+
+	return d
+}
+
+// Trie is a dictionary of words to search with LevenshteinAutomaton
+//
+// Trie is safe for concurrent use.
+type Trie struct {
+	mu    sync.RWMutex
+	nodes []trieNode
+	words []string
+}
+
+type trieNode struct {
+	children map[rune]int
+	// Index of the word ended in the node or -1.
+	word int
+}
+
+// NewTrie is a constructor.
+func NewTrie() *Trie {
+	return &Trie{nodes: []trieNode{{word: -1}}}
+}
+
+// Add words to trie
+//
+// Words are indexed in order of adding. Words already contained in trie are
+// skipped.
+func (trie *Trie) Add(words ...string) {
+	trie.mu.Lock()
+	defer trie.mu.Unlock()
+
+	for _, word := range words {
+		i := 0
+
+		for _, r := range word {
+			child, ok := trie.nodes[i].children[r]
+			if !ok {
+				if trie.nodes[i].children == nil {
+					trie.nodes[i].children = map[rune]int{}
+				}
+
+				child = len(trie.nodes)
+				trie.nodes[i].children[r] = child
+				trie.nodes = append(trie.nodes, trieNode{word: -1})
+			}
+
+			i = child
+		}
+
+		if trie.nodes[i].word < 0 {
+			trie.nodes[i].word = len(trie.words)
+			trie.words = append(trie.words, word)
+		}
+	}
+}
+
+// Len return number of words in trie.
+func (trie *Trie) Len() int {
+	trie.mu.RLock()
+	defer trie.mu.RUnlock()
+
+	return len(trie.words)
+}
+
+// Get word by index.
+func (trie *Trie) Get(i int) string {
+	trie.mu.RLock()
+	defer trie.mu.RUnlock()
+
+	if i < 0 || i >= len(trie.words) {
+		return ""
+	}
+
+	return trie.words[i]
+}
+
+// Search return every word accepted by automaton
+//
+// Subtrees are skipped as soon as state is dead. Hits are sorted by distance
+// and index.
+func (trie *Trie) Search(a *LevenshteinAutomaton) []BKHit {
+	trie.mu.RLock()
+	defer trie.mu.RUnlock()
+
+	type item struct {
+		node  int
+		state AutomatonState
+	}
+
+	var hits []BKHit
+
+	stack := []item{{node: 0, state: a.Start()}}
+
+	for len(stack) > 0 {
+		it := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		node := &trie.nodes[it.node]
+
+		if node.word >= 0 {
+			if d := a.Distance(it.state); d >= 0 {
+				hits = append(hits, BKHit{Index: node.word, String: trie.words[node.word], Distance: d})
+			}
+		}
+
+		for r, child := range node.children {
+			if state := a.Step(it.state, r); a.CanMatch(state) {
+				stack = append(stack, item{node: child, state: state})
+			}
+		}
+	}
+
+	sortBKHits(hits)
+
+	return hits
+}
+
+// SearchSorted return every word of sorted list accepted by automaton
+//
+// States of common prefix of consecutive words are reused, and words with
+// dead prefix are skipped by binary search. Index of hit is a position in
+// list. Hits are sorted by distance and index.
+func SearchSorted(words []string, a *LevenshteinAutomaton) []BKHit {
+	var hits []BKHit
+
+	// States after every rune of the previous word and byte offsets of
+	// their prefixes.
+	states := []AutomatonState{a.Start()}
+	offsets := []int{0}
+	prev := ""
+
+	for i := 0; i < len(words); {
+		word := words[i]
+
+		// Drop states beyond common prefix with the previous word.
+		n := 1
+		for n < len(offsets) && offsets[n] <= len(word) && word[:offsets[n]] == prev[:offsets[n]] {
+			// Invalid UTF-8 sequence may be decoded differently.
+			if _, size := utf8.DecodeRuneInString(word[offsets[n-1]:]); offsets[n-1]+size != offsets[n] {
+				break
+			}
+
+			n++
+		}
+
+		states, offsets = states[:n], offsets[:n]
+		prev = word
+
+		dead := false
+
+		for offset := offsets[n-1]; offset < len(word); {
+			state := states[len(states)-1]
+			if !a.CanMatch(state) {
+				dead = true
+
+				break
+			}
+
+			r, size := utf8.DecodeRuneInString(word[offset:])
+			offset += size
+			states = append(states, a.Step(state, r))
+			offsets = append(offsets, offset)
+		}
+
+		if dead {
+			prefix := word[:offsets[len(offsets)-1]]
+			i += sort.Search(len(words)-i, func(j int) bool {
+				w := words[i+j]
+
+				return w > prefix && !strings.HasPrefix(w, prefix)
+			})
+
+			continue
+		}
+
+		if d := a.Distance(states[len(states)-1]); d >= 0 {
+			hits = append(hits, BKHit{Index: i, String: word, Distance: d})
+		}
+
+		i++
+	}
+
+	sortBKHits(hits)
+
+	return hits
+}
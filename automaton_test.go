@@ -0,0 +1,169 @@
+package muzzy_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/leanovate/gopter"
+	"github.com/leanovate/gopter/prop"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vporoshok/muzzy"
+)
+
+func TestLevenshteinAutomaton(t *testing.T) {
+	cases := [...]struct {
+		a, b     string
+		max, res int
+	}{
+		{"Something", "Smothing", 2, 2},
+		{"Something", "Smoething", 2, 2},
+		{"Something", "Some", 5, 5},
+		{"Something", "Som", 5, -1},
+		{"happiness", "princess", 4, 4},
+		{"happiness", "princess", -1, 4},
+		{"abba", "abba", 0, 0},
+		{"abba", "abbb", 0, -1},
+		{"", "abc", 3, 3},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.res, muzzy.NewLevenshteinAutomaton(c.a, c.max).Match(c.b), "%s/%s", c.a, c.b)
+	}
+
+	assert.Equal(t, 1, muzzy.NewDamerauAutomaton("Something", 2).Match("Smoething"))
+	assert.Equal(t, 2, muzzy.NewDamerauAutomaton("abba", 2).Match("baab"))
+
+	a := muzzy.NewLevenshteinAutomaton("milk", 1)
+	state := a.Step(a.Step(a.Start(), 's'), 'i')
+	assert.True(t, a.CanMatch(state))
+	assert.Equal(t, -1, a.Distance(state))
+	assert.False(t, a.CanMatch(a.Step(a.Step(state, 'x'), 'y')))
+}
+
+func TestAutomatonSearch(t *testing.T) {
+	words := corpusWords(t)[:5000]
+	trie := muzzy.NewTrie()
+	trie.Add(words...)
+	trie.Add(words[0])
+
+	require.Equal(t, len(words), trie.Len())
+	assert.Equal(t, words[1], trie.Get(1))
+	assert.Equal(t, "", trie.Get(len(words)))
+
+	sorted := append([]string(nil), words...)
+	sort.Strings(sorted)
+
+	queries := []string{"чичиков", "манилов", "собакевич", "коробочка", "гоголь", "x", ""}
+	for i := 0; i < len(words); i += 1000 {
+		queries = append(queries, words[i])
+	}
+
+	distances := map[string]struct {
+		distance  muzzy.Distance
+		automaton func(string, int) *muzzy.LevenshteinAutomaton
+	}{
+		"Levenshtein": {muzzy.LevenshteinDistance, muzzy.NewLevenshteinAutomaton},
+		"Damerau":     {muzzy.DamerauDistance, muzzy.NewDamerauAutomaton},
+	}
+
+	for name, d := range distances {
+		for _, query := range queries {
+			all := scanWithin(words, d.distance, query, 3)
+			allSorted := scanWithin(sorted, d.distance, query, 3)
+
+			for k := 0; k <= 3; k++ {
+				a := d.automaton(query, k)
+
+				expected := within(all, k)
+				if len(expected) == 0 {
+					assert.Empty(t, trie.Search(a), "%s %q within %d", name, query, k)
+				} else {
+					assert.Equal(t, expected, trie.Search(a), "%s %q within %d", name, query, k)
+				}
+
+				expected = within(allSorted, k)
+				if len(expected) == 0 {
+					assert.Empty(t, muzzy.SearchSorted(sorted, a), "%s %q within %d", name, query, k)
+				} else {
+					assert.Equal(t, expected, muzzy.SearchSorted(sorted, a), "%s %q within %d", name, query, k)
+				}
+			}
+		}
+	}
+
+	assert.Len(t, muzzy.SearchSorted(sorted, muzzy.NewLevenshteinAutomaton("", -1)), len(sorted))
+	assert.Empty(t, muzzy.NewTrie().Search(muzzy.NewLevenshteinAutomaton("", 1)))
+}
+
+// Hits within distance k of hits sorted by distance.
+func within(hits []muzzy.BKHit, k int) []muzzy.BKHit {
+	return hits[:sort.Search(len(hits), func(i int) bool { return hits[i].Distance > k })]
+}
+
+func TestAutomatonProperties(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	properties := gopter.NewProperties(nil)
+
+	properties.Property("Levenshtein automaton same as distance", prop.ForAll(
+		func(pair Pair) bool {
+			for k := -1; k <= pair.changes; k++ {
+				a := muzzy.NewLevenshteinAutomaton(string(pair.a), k).Match(string(pair.b))
+				d := muzzy.LevenshteinDistance(string(pair.a), string(pair.b), k)
+
+				if a != d {
+					t.Logf("%s k=%d a=%d, d=%d", pair, k, a, d)
+
+					return false
+				}
+			}
+
+			return true
+		},
+		PairGenerator(),
+	))
+	properties.Property("Damerau automaton same as distance", prop.ForAll(
+		func(pair Pair) bool {
+			for k := -1; k <= pair.changes; k++ {
+				a := muzzy.NewDamerauAutomaton(string(pair.a), k).Match(string(pair.b))
+				d := muzzy.DamerauDistance(string(pair.a), string(pair.b), k)
+
+				if a != d {
+					t.Logf("%s k=%d a=%d, d=%d", pair, k, a, d)
+
+					return false
+				}
+			}
+
+			return true
+		},
+		PairGenerator(),
+	))
+
+	properties.TestingRun(t)
+}
+
+func BenchmarkAutomaton(b *testing.B) {
+	words := corpusWords(b)
+	trie := muzzy.NewTrie()
+	trie.Add(words...)
+
+	sorted := append([]string(nil), words...)
+	sort.Strings(sorted)
+
+	b.Run("Trie", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			trie.Search(muzzy.NewLevenshteinAutomaton(words[i%len(words)], 2))
+		}
+	})
+
+	b.Run("Sorted", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			muzzy.SearchSorted(sorted, muzzy.NewLevenshteinAutomaton(words[i%len(words)], 2))
+		}
+	})
+}
@@ -0,0 +1,145 @@
+package muzzy
+
+import (
+	"runtime"
+	"sync"
+)
+
+// BatchResult is a result of one query of batch search.
+type BatchResult struct {
+	// Index of the query in the input.
+	Index int
+	// Query is the searched string.
+	Query string
+	// Hits are the same as SearchTopK return.
+	Hits []Hit
+}
+
+// SearchBatch search top k hits for every query in parallel
+//
+// Queries are distributed among workers goroutines (GOMAXPROCS by default).
+// Every worker reuse its own dense accumulator of one float64 per indexed
+// string between queries. Result of i-th query is i-th element of returned
+// slice.
+func (index *SplitIndex) SearchBatch(queries []string, k, workers int) [][]Hit {
+	results := make([][]Hit, len(queries))
+	next := make(chan int)
+
+	var wg sync.WaitGroup
+
+	workers = batchWorkers(workers)
+	wg.Add(workers)
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+
+			accumulator := new([]float64)
+
+			for i := range next {
+				results[i] = index.searchTopK(queries[i], k, accumulator)
+			}
+		}()
+	}
+
+	for i := range queries {
+		next <- i
+	}
+
+	close(next)
+	wg.Wait()
+
+	return results
+}
+
+// SearchStream search top k hits for every query from channel in parallel
+//
+// Results are sent in the order of queries. Returned channel is closed after
+// queries channel is closed and all results are sent. Reader should read all
+// results to release goroutines.
+func (index *SplitIndex) SearchStream(queries <-chan string, k, workers int) <-chan BatchResult {
+	var wg sync.WaitGroup
+
+	workers = batchWorkers(workers)
+	read := make(chan BatchResult, workers)
+	found := make(chan BatchResult, workers)
+	results := make(chan BatchResult, workers)
+
+	go func() {
+		defer close(read)
+
+		i := 0
+		for query := range queries {
+			read <- BatchResult{Index: i, Query: query}
+			i++
+		}
+	}()
+
+	wg.Add(workers)
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+
+			accumulator := new([]float64)
+
+			for r := range read {
+				r.Hits = index.searchTopK(r.Query, k, accumulator)
+				found <- r
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(found)
+	}()
+
+	go func() {
+		defer close(results)
+
+		seq := 0
+		pending := map[int]BatchResult{}
+
+		for r := range found {
+			pending[r.Index] = r
+
+			for {
+				ready, ok := pending[seq]
+				if !ok {
+					break
+				}
+
+				delete(pending, seq)
+				results <- ready
+				seq++
+			}
+		}
+	}()
+
+	return results
+}
+
+// searchTopK is a SearchTopK with reused accumulator.
+func (index *SplitIndex) searchTopK(s string, k int, accumulator *[]float64) []Hit {
+	if k <= 0 {
+		return nil
+	}
+
+	ngrams := index.Split(s)
+	q := index.query(s, ngrams)
+	q.accumulator = accumulator
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	return q.TopK(k)
+}
+
+func batchWorkers(workers int) int {
+	if workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+
+	return workers
+}
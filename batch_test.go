@@ -0,0 +1,76 @@
+package muzzy_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vporoshok/muzzy"
+)
+
+func TestSplitIndexBatch(t *testing.T) {
+	lines := readCorpusLines(t)
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true))
+	index.Add(lines...)
+
+	queries := make([]string, 0, 200)
+	for i := 0; i < len(lines) && len(queries) < cap(queries); i += 37 {
+		queries = append(queries, strings.ToLower(lines[i]))
+	}
+
+	for _, workers := range [...]int{0, 1, 3} {
+		results := index.SearchBatch(queries, 5, workers)
+		require.Len(t, results, len(queries))
+
+		for i, query := range queries {
+			assert.Equal(t, index.SearchTopK(query, 5), results[i], query)
+		}
+
+		in := make(chan string)
+
+		go func() {
+			for _, query := range queries {
+				in <- query
+			}
+
+			close(in)
+		}()
+
+		i := 0
+		for r := range index.SearchStream(in, 5, workers) {
+			assert.Equal(t, i, r.Index)
+			assert.Equal(t, queries[i], r.Query)
+			assert.Equal(t, results[i], r.Hits, r.Query)
+			i++
+		}
+
+		assert.Equal(t, len(queries), i)
+	}
+
+	assert.Equal(t, [][]muzzy.Hit{nil}, index.SearchBatch([]string{"Чичиков"}, 0, 2))
+	assert.Empty(t, index.SearchBatch(nil, 5, 2))
+}
+
+func BenchmarkSplitIndexBatch(b *testing.B) {
+	lines := readCorpusLines(b)
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true))
+	index.Add(lines...)
+
+	queries := lines[:1000]
+
+	b.Run("Sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, query := range queries {
+				index.SearchTopK(query, 10)
+			}
+		}
+	})
+
+	b.Run("Batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			index.SearchBatch(queries, 10, 0)
+		}
+	})
+}
@@ -0,0 +1,375 @@
+package muzzy
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+const (
+	bkTreeMagic   = "muzzy-bktree"
+	bkTreeVersion = 1
+)
+
+// BKTree index to search words within given distance
+//
+// Every child of a node is keyed by its distance to the node, so by triangle
+// inequality only children with keys between d-k and d+k may contain words
+// within distance k from the query, where d is a distance from the query to
+// the node. Distance should be a metric, as LevenshteinDistance is.
+// DamerauDistance is an optimal string alignment distance, that may break
+// triangle inequality (distance between "ca" and "abc" is 3, but via "ac" it
+// is 2), so with it words near transpositions may be rarely missed. Bound of
+// distance is set to the greatest useful value, so long words are not
+// compared in full. BKTree is safe for concurrent use.
+type BKTree struct {
+	mu       sync.RWMutex
+	distance Distance
+	nodes    []bkNode
+}
+
+type bkNode struct {
+	word string
+	// Children by distance to the word.
+	children map[int]int
+	// Maximal distance to child.
+	maxEdge int
+}
+
+// BKHit is a result of search by distance in BKTree, Trie or sorted list.
+type BKHit struct {
+	// Index of the word in tree.
+	Index int
+	// String is the word.
+	String string
+	// Distance from the query to the word.
+	Distance int
+}
+
+// NewBKTree is a constructor.
+func NewBKTree(distance Distance) *BKTree {
+	return &BKTree{distance: distance}
+}
+
+// Add words to tree
+//
+// Words are indexed in order of adding. Words already contained in tree are
+// skipped.
+func (tree *BKTree) Add(words ...string) {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+
+	for _, word := range words {
+		tree.add(word)
+	}
+}
+
+func (tree *BKTree) add(word string) {
+	if len(tree.nodes) == 0 {
+		tree.nodes = append(tree.nodes, bkNode{word: word})
+
+		return
+	}
+
+	for i := 0; ; {
+		node := &tree.nodes[i]
+
+		d := tree.distance(word, node.word, -1)
+		if d == 0 {
+			return
+		}
+
+		if child, ok := node.children[d]; ok {
+			i = child
+
+			continue
+		}
+
+		if node.children == nil {
+			node.children = map[int]int{}
+		}
+
+		node.children[d] = len(tree.nodes)
+		if d > node.maxEdge {
+			node.maxEdge = d
+		}
+
+		tree.nodes = append(tree.nodes, bkNode{word: word})
+
+		return
+	}
+}
+
+// Len return number of words in tree.
+func (tree *BKTree) Len() int {
+	tree.mu.RLock()
+	defer tree.mu.RUnlock()
+
+	return len(tree.nodes)
+}
+
+// Get word by index.
+func (tree *BKTree) Get(i int) string {
+	tree.mu.RLock()
+	defer tree.mu.RUnlock()
+
+	if i < 0 || i >= len(tree.nodes) {
+		return ""
+	}
+
+	return tree.nodes[i].word
+}
+
+// SearchWithin return all words within distance k from s
+//
+// Hits are sorted by distance and index.
+func (tree *BKTree) SearchWithin(s string, k int) []BKHit {
+	if k < 0 {
+		return nil
+	}
+
+	tree.mu.RLock()
+	defer tree.mu.RUnlock()
+
+	var hits []BKHit
+
+	tree.walk(s, func() int { return k }, func(hit BKHit) {
+		hits = append(hits, hit)
+	})
+
+	sortBKHits(hits)
+
+	return hits
+}
+
+// SearchNearest return up to n words nearest to s
+//
+// Hits are sorted by distance and index.
+func (tree *BKTree) SearchNearest(s string, n int) []BKHit {
+	if n <= 0 {
+		return nil
+	}
+
+	tree.mu.RLock()
+	defer tree.mu.RUnlock()
+
+	top := make(bkHitHeap, 0, min(n, len(tree.nodes)))
+
+	// Until n words are found radius is unlimited, then it is the distance
+	// of the worst of them.
+	radius := func() int {
+		if len(top) < n {
+			return maxInt
+		}
+
+		return top[0].Distance
+	}
+
+	tree.walk(s, radius, func(hit BKHit) {
+		switch {
+		case len(top) < n:
+			heap.Push(&top, hit)
+		case bkBetter(hit, top[0]):
+			top[0] = hit
+			heap.Fix(&top, 0)
+		}
+	})
+
+	sortBKHits(top)
+
+	return top
+}
+
+// Walk tree and call found for every word within radius from s. Radius may
+// decrease during walk.
+func (tree *BKTree) walk(s string, radius func() int, found func(BKHit)) {
+	if len(tree.nodes) == 0 {
+		return
+	}
+
+	stack := []int{0}
+
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		node := &tree.nodes[i]
+
+		// Children are useful only if distance to the node is not greater
+		// than radius plus maximal edge.
+		r := radius()
+
+		bound := -1
+		if r < maxInt-node.maxEdge {
+			bound = r + node.maxEdge
+		}
+
+		d := tree.distance(s, node.word, bound)
+		if d < 0 {
+			continue
+		}
+
+		if d <= r {
+			found(BKHit{Index: i, String: node.word, Distance: d})
+			r = radius()
+		}
+
+		for edge, child := range node.children {
+			if edge-d <= r && d-edge <= r {
+				stack = append(stack, child)
+			}
+		}
+	}
+}
+
+func sortBKHits(hits []BKHit) {
+	sort.Slice(hits, func(i, j int) bool {
+		return bkBetter(hits[i], hits[j])
+	})
+}
+
+func bkBetter(a, b BKHit) bool {
+	if a.Distance != b.Distance {
+		return a.Distance < b.Distance
+	}
+
+	return a.Index < b.Index
+}
+
+// bkHitHeap keep nearest hits with the farthest one on top.
+type bkHitHeap []BKHit
+
+func (h bkHitHeap) Len() int            { return len(h) }
+func (h bkHitHeap) Less(i, j int) bool  { return bkBetter(h[j], h[i]) }
+func (h bkHitHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *bkHitHeap) Push(x interface{}) { *h = append(*h, x.(BKHit)) }
+
+func (h *bkHitHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return x
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (tree *BKTree) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+
+	if _, err := tree.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (tree *BKTree) UnmarshalBinary(data []byte) error {
+	_, err := tree.ReadFrom(bytes.NewReader(data))
+
+	return err
+}
+
+// WriteTo write tree to w
+//
+// Format starts with a header of magic string and version, followed by
+// words in order of indexes. Every word except the first one is followed by
+// index of its parent and distance to it, so tree is read without distance
+// calculation. Distance function is not written, and tree should be read with
+// the same one.
+func (tree *BKTree) WriteTo(w io.Writer) (int64, error) {
+	tree.mu.RLock()
+	defer tree.mu.RUnlock()
+
+	bw := &binaryWriter{w: bufio.NewWriter(w)}
+	bw.String(bkTreeMagic)
+	bw.Uvarint(bkTreeVersion)
+	bw.Uvarint(uint64(len(tree.nodes)))
+
+	parents := make([]int, len(tree.nodes))
+	edges := make([]int, len(tree.nodes))
+
+	for i := range tree.nodes {
+		for edge, child := range tree.nodes[i].children {
+			parents[child], edges[child] = i, edge
+		}
+	}
+
+	for i, node := range tree.nodes {
+		bw.String(node.word)
+
+		if i > 0 {
+			bw.Uvarint(uint64(parents[i]))
+			bw.Uvarint(uint64(edges[i]))
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ReadFrom replace tree content with tree read from r
+//
+// If r is not an io.ByteReader it is buffered, so r may be read beyond the
+// tree end.
+func (tree *BKTree) ReadFrom(r io.Reader) (int64, error) {
+	br := newBinaryReader(r)
+
+	magic := br.String()
+	version := br.Uvarint()
+
+	if br.err != nil || magic != bkTreeMagic {
+		return br.n, ErrInvalidFormat
+	}
+
+	if version != bkTreeVersion {
+		return br.n, fmt.Errorf("muzzy: unsupported BK-tree version %d", version)
+	}
+
+	n := br.Len()
+	nodes := make([]bkNode, 0, min(n, maxPrealloc))
+
+	for i := 0; i < n && br.err == nil; i++ {
+		nodes = append(nodes, bkNode{word: br.String()})
+
+		if i == 0 {
+			continue
+		}
+
+		parent, edge := br.Len(), br.Len()
+		if br.err != nil {
+			break
+		}
+
+		if parent >= i || edge == 0 {
+			return br.n, ErrInvalidFormat
+		}
+
+		node := &nodes[parent]
+		if _, ok := node.children[edge]; ok {
+			return br.n, ErrInvalidFormat
+		}
+
+		if node.children == nil {
+			node.children = map[int]int{}
+		}
+
+		node.children[edge] = i
+		if edge > node.maxEdge {
+			node.maxEdge = edge
+		}
+	}
+
+	if br.err != nil {
+		return br.n, br.err
+	}
+
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+
+	tree.nodes = nodes
+
+	return br.n, nil
+}
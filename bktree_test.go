@@ -0,0 +1,152 @@
+package muzzy_test
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vporoshok/muzzy"
+)
+
+// Search all words within distance k by full scan.
+func scanWithin(words []string, distance muzzy.Distance, s string, k int) []muzzy.BKHit {
+	var hits []muzzy.BKHit
+
+	for i, word := range words {
+		if d := distance(s, word, k); d >= 0 {
+			hits = append(hits, muzzy.BKHit{Index: i, String: word, Distance: d})
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Distance < hits[j].Distance
+	})
+
+	return hits
+}
+
+func TestBKTree(t *testing.T) {
+	words := corpusWords(t)[:5000]
+	tree := muzzy.NewBKTree(muzzy.LevenshteinDistance)
+	tree.Add(words...)
+	tree.Add(words[0], words[1])
+
+	require.Equal(t, len(words), tree.Len())
+	assert.Equal(t, words[1], tree.Get(1))
+	assert.Equal(t, "", tree.Get(-1))
+	assert.Equal(t, "", tree.Get(len(words)))
+
+	queries := []string{"чичиков", "манилов", "собакевич", "коробочка", "гоголь", "x", ""}
+	for i := 0; i < len(words); i += 1000 {
+		queries = append(queries, words[i])
+	}
+
+	for _, query := range queries {
+		all := scanWithin(words, muzzy.LevenshteinDistance, query, -1)
+
+		for k := 0; k <= 3; k++ {
+			expected := all[:sort.Search(len(all), func(i int) bool { return all[i].Distance > k })]
+			actual := tree.SearchWithin(query, k)
+
+			if len(expected) == 0 {
+				assert.Empty(t, actual, "%q within %d", query, k)
+			} else {
+				assert.Equal(t, expected, actual, "%q within %d", query, k)
+			}
+		}
+
+		for _, n := range [...]int{1, 5, 20} {
+			assert.Equal(t, all[:n], tree.SearchNearest(query, n), "%q nearest %d", query, n)
+		}
+	}
+
+	assert.Empty(t, tree.SearchWithin("чичиков", -1))
+	assert.Empty(t, tree.SearchNearest("чичиков", 0))
+	assert.Empty(t, muzzy.NewBKTree(muzzy.LevenshteinDistance).SearchNearest("чичиков", 1))
+}
+
+func TestBKTreeDamerau(t *testing.T) {
+	tree := muzzy.NewBKTree(muzzy.DamerauDistance)
+	tree.Add("permutation", "permission", "mutation", "station")
+
+	hits := tree.SearchWithin("permtuation", 1)
+	if assert.Len(t, hits, 1) {
+		assert.Equal(t, muzzy.BKHit{Index: 0, String: "permutation", Distance: 1}, hits[0])
+	}
+
+	words := corpusWords(t)[:5000]
+	tree = muzzy.NewBKTree(muzzy.DamerauDistance)
+	tree.Add(words...)
+
+	// Distance is not a metric, so only found hits are checked.
+	for i := 0; i < len(words); i += 250 {
+		for _, hit := range tree.SearchWithin(words[i], 2) {
+			assert.Equal(t, muzzy.DamerauDistance(words[i], hit.String, -1), hit.Distance)
+			assert.True(t, hit.Distance <= 2)
+		}
+	}
+}
+
+func TestBKTreeMarshaling(t *testing.T) {
+	words := corpusWords(t)[:5000]
+	tree := muzzy.NewBKTree(muzzy.LevenshteinDistance)
+	tree.Add(words...)
+
+	data, err := tree.MarshalBinary()
+	require.NoError(t, err)
+
+	restored := muzzy.NewBKTree(muzzy.LevenshteinDistance)
+	require.NoError(t, restored.UnmarshalBinary(data))
+	assert.Equal(t, tree.Len(), restored.Len())
+
+	for _, query := range [...]string{"чичиков", "манилов", "not found"} {
+		assert.Equal(t, tree.SearchWithin(query, 2), restored.SearchWithin(query, 2), query)
+		assert.Equal(t, tree.SearchNearest(query, 10), restored.SearchNearest(query, 10), query)
+	}
+
+	var buf bytes.Buffer
+
+	n, err := restored.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+
+	small := muzzy.NewBKTree(muzzy.LevenshteinDistance)
+	small.Add("milk", "silk", "happiness", "princess")
+
+	data, err = small.MarshalBinary()
+	require.NoError(t, err)
+
+	for i := 0; i < len(data); i++ {
+		err = new(muzzy.BKTree).UnmarshalBinary(data[:i])
+		assert.Equal(t, muzzy.ErrInvalidFormat, err, "truncated to %d", i)
+	}
+
+	index, err := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true)).MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, muzzy.ErrInvalidFormat, new(muzzy.BKTree).UnmarshalBinary(index))
+
+	// Version follows length-prefixed magic string.
+	data[1+len("muzzy-bktree")] = 2
+	assert.EqualError(t, new(muzzy.BKTree).UnmarshalBinary(data), "muzzy: unsupported BK-tree version 2")
+}
+
+func BenchmarkBKTree(b *testing.B) {
+	words := corpusWords(b)
+	tree := muzzy.NewBKTree(muzzy.LevenshteinDistance)
+	tree.Add(words...)
+
+	b.Run("BKTree", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			tree.SearchWithin(words[i%len(words)], 2)
+		}
+	})
+
+	b.Run("Scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			scanWithin(words, muzzy.LevenshteinDistance, words[i%len(words)], 2)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package muzzy
+
+import "math"
+
+// Counter is a splitter, that count repeated n-grams of string.
+type Counter interface {
+	Count(s string) map[string]int
+}
+
+// Count number of occurrences of every n-gram.
+func (fn SplitterFunc) Count(s string) map[string]int {
+	counts := map[string]int{}
+	for _, gram := range fn(s) {
+		counts[gram]++
+	}
+
+	return counts
+}
+
+// Default BM25 parameters.
+const (
+	DefaultBM25K1 = 1.2
+	DefaultBM25B  = 0.75
+)
+
+type bm25 struct {
+	k1, b float64
+}
+
+// WithBM25 rank search results by Okapi BM25 instead of coefficient
+//
+// Term frequency of n-gram is a number of its occurrences in string, if the
+// splitter is a Counter, and 1 otherwise. Document length is a number of
+// different n-grams of string. Parameter k1 control saturation of term
+// frequency and b control length normalization (DefaultBM25K1 and
+// DefaultBM25B are common choices). Inverse document frequency is calculated
+// by the index itself. BM25 scores are not bounded by 1. BM25 takes
+// precedence over WithIDF.
+func WithBM25(k1, b float64) IndexOption {
+	return func(opts *indexOptions) {
+		opts.bm25 = &bm25{k1: k1, b: b}
+	}
+}
+
+// IDF of n-gram contained in df of n strings.
+func (params *bm25) IDF(df, n int) float64 {
+	return math.Log(1 + (float64(n-df)+0.5)/(float64(df)+0.5))
+}
+
+// Weight of n-gram with given IDF and term frequency in string of given size.
+func (params *bm25) Weight(idf float64, tf, size int, avgSize float64) float64 {
+	norm := 1 - params.b
+	if avgSize > 0 {
+		norm += params.b * float64(size) / avgSize
+	}
+
+	return idf * float64(tf) * (params.k1 + 1) / (float64(tf) + params.k1*norm)
+}
+
+// Return n-grams of s occurred more than once with number of occurrences or
+// nil if there are no such n-grams or splitter is not a Counter.
+func repeatedGrams(splitter Splitter, s string) map[string]int {
+	counter, ok := splitter.(Counter)
+	if !ok {
+		return nil
+	}
+
+	var repeated map[string]int
+
+	for ngram, n := range counter.Count(s) {
+		if n > 1 {
+			if repeated == nil {
+				repeated = map[string]int{}
+			}
+
+			repeated[ngram] = n
+		}
+	}
+
+	return repeated
+}
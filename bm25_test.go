@@ -0,0 +1,101 @@
+package muzzy_test
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vporoshok/muzzy"
+)
+
+func TestSplitterFuncCount(t *testing.T) {
+	splitter := muzzy.NGramSplitter(2, false).(muzzy.Counter)
+	assert.Equal(t, map[string]int{"ab": 2, "ba": 1}, splitter.Count("abab"))
+}
+
+func TestSplitIndexBM25(t *testing.T) {
+	const k1, b = 1.2, 0.75
+
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(2, false), muzzy.WithBM25(k1, b))
+	index.Add("abab", "ab", "cd")
+
+	idf := math.Log(1 + (3-2+0.5)/(2+0.5))
+	avg := 4.0 / 3
+	weight := func(tf, size float64) float64 {
+		return idf * tf * (k1 + 1) / (tf + k1*(1-b+b*size/avg))
+	}
+
+	hits := index.SearchTopK("ab", 5)
+	if assert.Len(t, hits, 2) {
+		assert.Equal(t, 0, hits[0].Index)
+		assert.InDelta(t, weight(2, 2), hits[0].Score, 1e-9)
+		assert.Equal(t, 1, hits[1].Index)
+		assert.InDelta(t, weight(1, 1), hits[1].Score, 1e-9)
+	}
+
+	for _, hit := range hits {
+		e, ok := index.Explain("ab", hit.Index)
+		require.True(t, ok)
+		assert.Equal(t, hit.Score, e.Score)
+		assert.Equal(t, hit.Score, e.Weight)
+		assert.Contains(t, e.Describe(), "score = sum of weights")
+	}
+
+	assert.Equal(t, hits[:1], index.SearchThreshold("ab", hits[0].Score))
+
+	hit, ok := index.Find("ab")
+	assert.True(t, ok)
+	assert.Equal(t, hits[1], hit)
+
+	index.Remove(2)
+
+	// Removed strings are not counted in number of strings.
+	idf = math.Log(1 + (2-2+0.5)/(2+0.5))
+	avg = 3.0 / 2
+	assert.InDelta(t, weight(1, 1), index.SearchTopK("ab", 5)[1].Score, 1e-9)
+
+	index.Update(0, "ba")
+
+	hits = index.SearchTopK("ab", 5)
+	if assert.Len(t, hits, 1) {
+		assert.Equal(t, 1, hits[0].Index)
+		assert.True(t, hits[0].Exact)
+	}
+}
+
+func TestSplitIndexBM25Persistence(t *testing.T) {
+	splitter := muzzy.NGramSplitter(3, true)
+	option := muzzy.WithBM25(muzzy.DefaultBM25K1, muzzy.DefaultBM25B)
+	index := muzzy.NewSplitIndex(splitter, option)
+	index.Add(readCorpusLines(t)...)
+	index.Remove(1)
+
+	query := `"Что ж баирн? у себя, что ли?"`
+	hits := index.SearchTopK(query, 10)
+	require.NotEmpty(t, hits)
+	assert.Equal(t, `"Что ж барин? у себя, что ли?"`, hits[0].String)
+
+	data, err := index.MarshalBinary()
+	require.NoError(t, err)
+
+	restored := muzzy.NewSplitIndex(splitter, option)
+	require.NoError(t, restored.UnmarshalBinary(data))
+	assert.Equal(t, hits, restored.SearchTopK(query, 10))
+
+	path := writeDiskIndex(t, index)
+	defer os.Remove(path)
+
+	disk, err := muzzy.OpenDiskIndex(path, nil, option)
+	require.NoError(t, err)
+
+	defer disk.Close()
+
+	assert.Equal(t, hits, disk.SearchTopK(query, 10))
+
+	expected, _ := index.Find(query)
+	actual, _ := disk.Find(query)
+	assert.Equal(t, expected, actual)
+}
@@ -0,0 +1,56 @@
+package muzzy_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Path of the test corpus: text of "Dead Souls" by Nikolai Gogol.
+var corpusPath = filepath.Join("testdata", "dead_souls.txt")
+
+// Text of corpus.
+func readCorpus(t testing.TB) string {
+	corpus, err := ioutil.ReadFile(corpusPath)
+	require.NoError(t, err)
+
+	return string(corpus)
+}
+
+// All lines of corpus including empty ones.
+func readCorpusLines(t testing.TB) []string {
+	return strings.Split(readCorpus(t), "\n")
+}
+
+// The first n lines of corpus up to 300 bytes long.
+func corpusLines(t testing.TB, n int) []string {
+	var lines []string
+
+	for _, line := range readCorpusLines(t) {
+		if line = strings.TrimSpace(line); line != "" && len(line) <= 300 && len(lines) < n {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+// Distinct lower case words of corpus in order of appearance.
+func corpusWords(t testing.TB) []string {
+	seen := map[string]bool{}
+
+	var words []string
+
+	for _, word := range strings.Fields(readCorpus(t)) {
+		word = strings.ToLower(strings.Trim(word, `.,;:!?"()«»—-`))
+		if word != "" && !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+
+	return words
+}
@@ -0,0 +1,867 @@
+package muzzy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+const (
+	diskMagic   = "muzzydsk"
+	diskVersion = 4
+)
+
+// Header of disk index is a magic string followed by little-endian uint64
+// fields. Offsets of sections are counted from the file start, offsets in
+// tables are counted from the start of corresponding data section.
+const (
+	diskVersionField = iota
+	diskSplitterField
+	diskNField
+	diskPaddingField
+	diskStringsField
+	// Number of not removed strings.
+	diskLiveField
+	diskNGramsField
+	diskSizesField
+	diskRemovedField
+	diskStringOffsetsField
+	diskNGramOffsetsField
+	diskPostingOffsetsField
+	// Number of postings of every n-gram.
+	diskCountsField
+	// Hash table of normalized strings and number of its slots.
+	diskExactField
+	diskExactSlotsField
+	diskStringDataField
+	diskNGramDataField
+	diskPostingDataField
+	// IDF norms of strings or 0, if index has no IDF model.
+	diskNormsField
+	// Term frequencies great than 1 of every posting list.
+	diskTFOffsetsField
+	diskTFDataField
+	// Skip pointers of every posting list.
+	diskSkipOffsetsField
+	diskSkipDataField
+	// Payloads encoded with encoding/gob and their size or 0, if index has no
+	// payloads.
+	diskPayloadsField
+	diskPayloadsSizeField
+	diskHeaderFields
+)
+
+const diskHeaderSize = len(diskMagic) + 8*diskHeaderFields
+
+// DiskIndex is a read-only n-gram index stored in file
+//
+// File written by SplitIndex.WriteDisk is memory-mapped, so index is searched
+// without reading it to heap. The file contains sorted n-grams dictionary,
+// offset tables, posting counts, packed posting lists and ranking data. Only
+// payloads are decoded to heap on open. Search results are the same as of
+// SplitIndex with the same options.
+// DiskIndex is safe for concurrent use.
+type DiskIndex struct {
+	Splitter
+	indexOptions
+	data    []byte
+	release func() error
+	header  [diskHeaderFields]int
+	// IDF norms calculated on open, if file has no norms.
+	norms []float64
+	// Average size of not removed strings.
+	avg float64
+	// Payloads decoded on open or nil, if file has no payloads.
+	payloads []interface{}
+}
+
+// WriteDisk write index in DiskIndex format to w
+//
+// Removed strings are dropped from posting lists, but their indexes are kept.
+// Splitter configuration and payloads are written as by WriteTo, so custom
+// payload types should be registered with gob.Register.
+// IDF norms of index with IDF model and term frequencies of n-grams are
+// written, so DiskIndex ranks strings without splitting them.
+func (index *SplitIndex) WriteDisk(w io.Writer) (int64, error) {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	payloads, err := index.encodePayloads()
+	if err != nil {
+		return 0, err
+	}
+
+	var header [diskHeaderFields]int
+
+	header[diskVersionField] = diskVersion
+
+	if splitter, ok := index.Splitter.(nGramSplitter); ok {
+		header[diskSplitterField] = int(nGramSplitterKind)
+		header[diskNField] = splitter.n
+
+		if splitter.withPadding {
+			header[diskPaddingField] = 1
+		}
+	}
+
+	ngrams := index.sortedGrams()
+	postings, tfs, skips := index.packPostings(ngrams)
+	n, m := len(index.strings), len(ngrams)
+	header[diskStringsField] = n
+	header[diskLiveField] = index.live
+	header[diskNGramsField] = m
+	header[diskSizesField] = diskHeaderSize
+	header[diskRemovedField] = header[diskSizesField] + 4*n
+	header[diskStringOffsetsField] = header[diskRemovedField] + n
+	header[diskNGramOffsetsField] = header[diskStringOffsetsField] + 8*(n+1)
+	header[diskPostingOffsetsField] = header[diskNGramOffsetsField] + 8*(m+1)
+	header[diskCountsField] = header[diskPostingOffsetsField] + 8*(m+1)
+	header[diskExactField] = header[diskCountsField] + 4*m
+	header[diskExactSlotsField] = 2*index.live + 1
+	header[diskStringDataField] = header[diskExactField] + 4*header[diskExactSlotsField]
+	header[diskNGramDataField] = header[diskStringDataField] + totalLen(index.strings)
+	header[diskPostingDataField] = header[diskNGramDataField] + totalLen(ngrams)
+	header[diskTFOffsetsField] = header[diskPostingDataField] + totalSize(postings)
+
+	if index.idf != nil {
+		header[diskNormsField] = header[diskTFOffsetsField]
+		header[diskTFOffsetsField] += 8 * n
+	}
+
+	header[diskTFDataField] = header[diskTFOffsetsField] + 8*(m+1)
+	header[diskSkipOffsetsField] = header[diskTFDataField] + totalSize(tfs)
+	header[diskSkipDataField] = header[diskSkipOffsetsField] + 8*(m+1)
+
+	if payloads != nil {
+		header[diskPayloadsField] = header[diskSkipDataField] + totalSize(skips)
+		header[diskPayloadsSizeField] = len(payloads)
+	}
+
+	bw := &binaryWriter{w: bufio.NewWriter(w)}
+	bw.Raw(diskMagic)
+
+	for _, field := range header {
+		bw.Uint64(uint64(field))
+	}
+
+	index.writeDiskStrings(bw)
+	writeDiskNGrams(bw, ngrams, postings)
+
+	for _, ngram := range ngrams {
+		bw.Uint32(uint32(index.frequencies[index.grams[ngram]]))
+	}
+
+	for _, slot := range index.exactTable(header[diskExactSlotsField]) {
+		bw.Uint32(slot)
+	}
+
+	for _, s := range index.strings {
+		bw.Raw(s)
+	}
+
+	for _, ngram := range ngrams {
+		bw.Raw(ngram)
+	}
+
+	for _, packed := range postings {
+		bw.Write(packed)
+	}
+
+	if index.idf != nil {
+		for i, norm := range index.norms {
+			if index.removed[i] {
+				norm = 0
+			}
+
+			bw.Uint64(math.Float64bits(norm))
+		}
+	}
+
+	writeDiskSlices(bw, tfs)
+	writeDiskSlices(bw, skips)
+	bw.Write(payloads)
+
+	return bw.Flush()
+}
+
+func (index *SplitIndex) writeDiskStrings(bw *binaryWriter) {
+	for _, size := range index.sizes {
+		bw.Uint32(uint32(size))
+	}
+
+	for _, removed := range index.removed {
+		bw.Bool(removed)
+	}
+
+	offset := 0
+	bw.Uint64(0)
+
+	for _, s := range index.strings {
+		offset += len(s)
+		bw.Uint64(uint64(offset))
+	}
+}
+
+// Hash table of not removed strings by normalized string with linear probing.
+// Slot is an index of string plus 1 or 0 for empty slot. Strings are inserted
+// in order of indexes, so the first equal string met by probing has minimal
+// index.
+func (index *SplitIndex) exactTable(size int) []uint32 {
+	slots := make([]uint32, size)
+
+	for i, s := range index.strings {
+		if index.removed[i] {
+			continue
+		}
+
+		k := hashString(index.normalizeString(s)) % uint64(size)
+		for slots[k] != 0 {
+			k = (k + 1) % uint64(size)
+		}
+
+		slots[k] = uint32(i + 1)
+	}
+
+	return slots
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+
+	return h.Sum64()
+}
+
+// Write offsets table of slices followed by slices.
+func writeDiskSlices(bw *binaryWriter, slices [][]byte) {
+	offset := 0
+	bw.Uint64(0)
+
+	for _, packed := range slices {
+		offset += len(packed)
+		bw.Uint64(uint64(offset))
+	}
+
+	for _, packed := range slices {
+		bw.Write(packed)
+	}
+}
+
+func writeDiskNGrams(bw *binaryWriter, ngrams []string, postings [][]byte) {
+	offset := 0
+	bw.Uint64(0)
+
+	for _, ngram := range ngrams {
+		offset += len(ngram)
+		bw.Uint64(uint64(offset))
+	}
+
+	offset = 0
+	bw.Uint64(0)
+
+	for _, packed := range postings {
+		offset += len(packed)
+		bw.Uint64(uint64(offset))
+	}
+}
+
+// Posting lists are packed as in SplitIndex without removed strings. Term
+// frequencies great than 1 are packed for every list as pairs of uvarints:
+// delta of posting position in the list and the frequency. Skip pointers are
+// packed as pairs of little-endian uint32.
+func (index *SplitIndex) packPostings(ngrams []string) ([][]byte, [][]byte, [][]byte) {
+	postings := make([][]byte, len(ngrams))
+	tfs := make([][]byte, len(ngrams))
+	skips := make([][]byte, len(ngrams))
+	alive := func(i int) bool { return !index.removed[i] }
+
+	repeated := index.repeated
+	if repeated == nil {
+		repeated = make([]map[string]int, len(index.strings))
+		for i, s := range index.strings {
+			if alive(i) {
+				repeated[i] = repeatedGrams(index.Splitter, s)
+			}
+		}
+	}
+
+	var buf [binary.MaxVarintLen64]byte
+
+	for k, ngram := range ngrams {
+		list := index.postings[index.grams[ngram]]
+		list.Filter(alive)
+		postings[k] = list.data
+		skips[k] = make([]byte, 8*len(list.skips))
+
+		for j, skip := range list.skips {
+			binary.LittleEndian.PutUint32(skips[k][8*j:], skip.last)
+			binary.LittleEndian.PutUint32(skips[k][8*j+4:], skip.offset)
+		}
+
+		position, last := 0, 0
+
+		list.Each(func(i int) {
+			if tf, ok := repeated[i][ngram]; ok {
+				tfs[k] = append(tfs[k], buf[:binary.PutUvarint(buf[:], uint64(position-last))]...)
+				tfs[k] = append(tfs[k], buf[:binary.PutUvarint(buf[:], uint64(tf))]...)
+				last = position
+			}
+
+			position++
+		})
+	}
+
+	return postings, tfs, skips
+}
+
+func totalSize(bs [][]byte) int {
+	n := 0
+	for _, b := range bs {
+		n += len(b)
+	}
+
+	return n
+}
+
+func totalLen(ss []string) int {
+	n := 0
+	for _, s := range ss {
+		n += len(s)
+	}
+
+	return n
+}
+
+// OpenDiskIndex open index file written by SplitIndex.WriteDisk
+//
+// Splitter may be nil if index was written with splitter created by
+// NGramSplitter. Stored IDF norms are used with WithIDF, so the model should
+// be the same as of written index. Normalizer should be the same too, as
+// equal strings are found by hashes of written normalized strings. Index
+// should be closed after use.
+func OpenDiskIndex(path string, splitter Splitter, options ...IndexOption) (*DiskIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	data, release, err := mmapFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := newDiskIndex(data, splitter, options)
+	if err != nil {
+		_ = release()
+
+		return nil, err
+	}
+
+	index.release = release
+
+	return index, nil
+}
+
+func newDiskIndex(data []byte, splitter Splitter, options []IndexOption) (*DiskIndex, error) {
+	if len(data) < diskHeaderSize || string(data[:len(diskMagic)]) != diskMagic {
+		return nil, ErrInvalidFormat
+	}
+
+	index := &DiskIndex{indexOptions: newIndexOptions(options), data: data}
+	for i := range index.header {
+		x := binary.LittleEndian.Uint64(data[len(diskMagic)+8*i:])
+		if x > uint64(len(data)) {
+			return nil, ErrInvalidFormat
+		}
+
+		index.header[i] = int(x)
+	}
+
+	if version := index.header[diskVersionField]; version != diskVersion {
+		return nil, fmt.Errorf("muzzy: unsupported disk index version %d", version)
+	}
+
+	if err := index.validate(); err != nil {
+		return nil, err
+	}
+
+	var stored Splitter
+
+	switch index.header[diskSplitterField] {
+	case int(customSplitter):
+	case int(nGramSplitterKind):
+		if index.header[diskNField] == 0 {
+			return nil, ErrInvalidFormat
+		}
+
+		stored = NGramSplitter(index.header[diskNField], index.header[diskPaddingField] != 0)
+	default:
+		return nil, ErrInvalidFormat
+	}
+
+	var err error
+
+	if index.Splitter, err = restoreSplitter(splitter, stored); err != nil {
+		return nil, err
+	}
+
+	if offset := index.header[diskPayloadsField]; offset != 0 {
+		r := bytes.NewReader(data[offset : offset+index.header[diskPayloadsSizeField]])
+		if index.payloads, err = decodePayloads(r, index.header[diskStringsField]); err != nil {
+			return nil, err
+		}
+	}
+
+	index.prepareRanking()
+
+	return index, nil
+}
+
+// Norms are calculated only if index was written without IDF model. Average
+// size is calculated from sizes section.
+func (index *DiskIndex) prepareRanking() {
+	n := index.header[diskStringsField]
+
+	if index.idf != nil && index.header[diskNormsField] == 0 {
+		index.norms = make([]float64, n)
+		for i := range index.norms {
+			index.norms[i] = index.idf.norm(index.Split(index.text(i)))
+		}
+	}
+
+	if live := index.header[diskLiveField]; index.bm25 != nil && live > 0 {
+		sizeSum := 0
+
+		for i := 0; i < n; i++ {
+			if !index.removed(i) {
+				sizeSum += index.size(i)
+			}
+		}
+
+		index.avg = float64(sizeSum) / float64(live)
+	}
+}
+
+// Check sections layout and offset tables, so search never read data out of
+// sections.
+func (index *DiskIndex) validate() error {
+	h := &index.header
+	n, m := h[diskStringsField], h[diskNGramsField]
+	sections := [...]struct{ start, end int }{
+		{h[diskSizesField], h[diskSizesField] + 4*n},
+		{h[diskRemovedField], h[diskRemovedField] + n},
+		{h[diskStringOffsetsField], h[diskStringOffsetsField] + 8*(n+1)},
+		{h[diskNGramOffsetsField], h[diskNGramOffsetsField] + 8*(m+1)},
+		{h[diskPostingOffsetsField], h[diskPostingOffsetsField] + 8*(m+1)},
+		{h[diskCountsField], h[diskCountsField] + 4*m},
+		{h[diskExactField], h[diskExactField] + 4*h[diskExactSlotsField]},
+		{h[diskTFOffsetsField], h[diskTFOffsetsField] + 8*(m+1)},
+		{h[diskSkipOffsetsField], h[diskSkipOffsetsField] + 8*(m+1)},
+	}
+
+	for _, section := range sections {
+		if section.start < diskHeaderSize || section.end > len(index.data) {
+			return ErrInvalidFormat
+		}
+	}
+
+	if norms := h[diskNormsField]; norms != 0 && (norms < diskHeaderSize || norms+8*n > len(index.data)) {
+		return ErrInvalidFormat
+	}
+
+	payloads, size := h[diskPayloadsField], h[diskPayloadsSizeField]
+	if payloads != 0 && (payloads < diskHeaderSize || size > len(index.data)-payloads) {
+		return ErrInvalidFormat
+	}
+
+	tables := [...]struct{ table, data, count int }{
+		{h[diskStringOffsetsField], h[diskStringDataField], n},
+		{h[diskNGramOffsetsField], h[diskNGramDataField], m},
+		{h[diskPostingOffsetsField], h[diskPostingDataField], m},
+		{h[diskTFOffsetsField], h[diskTFDataField], m},
+		{h[diskSkipOffsetsField], h[diskSkipDataField], m},
+	}
+
+	for _, t := range tables {
+		last := 0
+
+		for i := 0; i <= t.count; i++ {
+			offset := index.uint64(t.table + 8*i)
+			if offset < last || offset > len(index.data)-t.data {
+				return ErrInvalidFormat
+			}
+
+			last = offset
+		}
+	}
+
+	return nil
+}
+
+// Close release index file. Index should not be used after close.
+func (index *DiskIndex) Close() error {
+	if index.release == nil {
+		return nil
+	}
+
+	err := index.release()
+	index.release = nil
+	index.data = nil
+
+	return err
+}
+
+// Get string by index.
+func (index *DiskIndex) Get(i int) string {
+	if i < 0 || i >= index.header[diskStringsField] || index.removed(i) {
+		return ""
+	}
+
+	return index.text(i)
+}
+
+// Payload of the string with given index.
+func (index *DiskIndex) Payload(i int) interface{} {
+	if i < 0 || i >= index.header[diskStringsField] || index.removed(i) {
+		return nil
+	}
+
+	return index.payload(i)
+}
+
+// Search index of maximal similar string in index
+//
+// Return -1 if no string n-gram found in index.
+func (index *DiskIndex) Search(s string) int {
+	hit, _ := index.Find(s)
+
+	return hit.Index
+}
+
+// Find string equal to s or maximal similar string (see SplitIndex)
+//
+// Equal strings are found by hash table of normalized strings stored in file.
+func (index *DiskIndex) Find(s string) (Hit, bool) {
+	q := index.query(s)
+
+	if i := index.exact(q.normalized); i >= 0 {
+		q.prepare()
+
+		return Hit{
+			Index:   i,
+			String:  index.text(i),
+			Payload: index.payload(i),
+			Score:   q.score(i, index.common(q, i)),
+			Exact:   true,
+		}, true
+	}
+
+	hits := q.TopK(1)
+	if len(hits) == 0 {
+		return Hit{Index: -1}, false
+	}
+
+	return hits[0], true
+}
+
+// Index of the first not removed string equal to the query after
+// normalization or -1.
+func (index *DiskIndex) exact(normalized string) int {
+	size := uint64(index.header[diskExactSlotsField])
+	if size == 0 {
+		return -1
+	}
+
+	table, n := index.header[diskExactField], index.header[diskStringsField]
+	k := hashString(normalized) % size
+
+	for probes := uint64(0); probes < size; probes++ {
+		i := int(binary.LittleEndian.Uint32(index.data[table+4*int(k):])) - 1
+		if i < 0 {
+			return -1
+		}
+
+		if i < n && !index.removed(i) && index.normalizeString(index.text(i)) == normalized {
+			return i
+		}
+
+		k = (k + 1) % size
+	}
+
+	return -1
+}
+
+// Count n-grams of i-th string shared with the query as query.count do.
+func (index *DiskIndex) common(q *query, i int) float64 {
+	s := index.text(i)
+	repeated := repeatedGrams(index.Splitter, s)
+	set := map[string]struct{}{}
+
+	for _, ngram := range index.Split(s) {
+		set[ngram] = struct{}{}
+	}
+
+	common := 0.0
+
+	for _, ngram := range q.ngrams {
+		if _, ok := set[ngram]; !ok {
+			continue
+		}
+
+		tf := 1
+		if n, ok := repeated[ngram]; ok {
+			tf = n
+		}
+
+		common += q.postingWeight(q.weight(ngram), i, tf)
+	}
+
+	return common
+}
+
+// SearchTopK return up to k strings most similar to s (see SplitIndex).
+func (index *DiskIndex) SearchTopK(s string, k int) []Hit {
+	hits, _ := index.SearchTopKContext(context.Background(), s, k)
+
+	return hits
+}
+
+// SearchTopKContext is a SearchTopK, that stop search on context cancellation
+// and return context error.
+func (index *DiskIndex) SearchTopKContext(ctx context.Context, s string, k int) ([]Hit, error) {
+	if k <= 0 {
+		return nil, nil
+	}
+
+	q := index.query(s)
+	q.ctx = ctx
+
+	return q.result(q.TopK(k))
+}
+
+// SearchThreshold return all strings with similarity to s great or equal to
+// threshold (see SplitIndex).
+func (index *DiskIndex) SearchThreshold(s string, threshold float64) []Hit {
+	hits, _ := index.SearchThresholdContext(context.Background(), s, threshold)
+
+	return hits
+}
+
+// SearchThresholdContext is a SearchThreshold, that stop search on context
+// cancellation and return context error.
+func (index *DiskIndex) SearchThresholdContext(ctx context.Context, s string, threshold float64) ([]Hit, error) {
+	q := index.query(s)
+	q.ctx = ctx
+
+	return q.result(q.Threshold(threshold))
+}
+
+// SearchRerank return up to n candidates re-scored with given algorithm (see
+// SplitIndex).
+func (index *DiskIndex) SearchRerank(s string, n int, algo similarityAlgorithm, threshold float64) []Hit {
+	hits, _ := index.SearchRerankContext(context.Background(), s, n, algo, threshold)
+
+	return hits
+}
+
+// SearchRerankContext is a SearchRerank, that stop search and distance
+// calculation on context cancellation and return context error.
+func (index *DiskIndex) SearchRerankContext(
+	ctx context.Context, s string, n int, algo similarityAlgorithm, threshold float64,
+) ([]Hit, error) {
+	candidates, err := index.SearchTopKContext(ctx, s, n)
+	if err != nil {
+		return nil, err
+	}
+
+	return rerank(ctx, s, candidates, algo, threshold)
+}
+
+func (index *DiskIndex) query(s string) *query {
+	return &query{
+		indexOptions: &index.indexOptions,
+		source:       index,
+		ngrams:       index.Split(s),
+		normalized:   index.normalizeString(s),
+	}
+}
+
+func (index *DiskIndex) eachPosting(ngram string, fn func(i, tf int) bool) {
+	k := index.gram(ngram)
+	if k < 0 {
+		return
+	}
+
+	packed := index.slice(diskPostingOffsetsField, diskPostingDataField, k)
+	tfs := tfReader{packed: index.slice(diskTFOffsetsField, diskTFDataField, k)}
+	tfs.read(0)
+
+	last, total := 0, index.header[diskStringsField]
+
+	for position := 0; len(packed) > 0; position++ {
+		// Posting data is not validated on open, so delta is checked before
+		// addition and crafted deltas do not overflow last.
+		delta, n := binary.Uvarint(packed)
+		if n <= 0 || delta >= uint64(total-last) {
+			return
+		}
+
+		packed = packed[n:]
+		last += int(delta)
+
+		if !fn(last, tfs.at(position)) {
+			return
+		}
+	}
+}
+
+func (index *DiskIndex) eachPostingOf(ngram string, ids []int, fn func(i, tf int) bool) {
+	k := index.gram(ngram)
+	if k < 0 {
+		return
+	}
+
+	packed := index.slice(diskPostingOffsetsField, diskPostingDataField, k)
+	skips := index.slice(diskSkipOffsetsField, diskSkipDataField, k)
+	skip := func(j int) postingSkip {
+		return postingSkip{
+			last:   binary.LittleEndian.Uint32(skips[8*j:]),
+			offset: binary.LittleEndian.Uint32(skips[8*j+4:]),
+		}
+	}
+
+	tfs := tfReader{packed: index.slice(diskTFOffsetsField, diskTFDataField, k)}
+	tfs.read(0)
+
+	seekPostings(packed, len(skips)/8, skip, ids, index.header[diskStringsField], func(position, i int) bool {
+		return fn(i, tfs.at(position))
+	})
+}
+
+// Position of n-gram in the dictionary or -1.
+func (index *DiskIndex) gram(ngram string) int {
+	h := &index.header
+	key := []byte(ngram)
+	k := sort.Search(h[diskNGramsField], func(k int) bool {
+		return bytes.Compare(index.slice(diskNGramOffsetsField, diskNGramDataField, k), key) >= 0
+	})
+
+	if k == h[diskNGramsField] || !bytes.Equal(index.slice(diskNGramOffsetsField, diskNGramDataField, k), key) {
+		return -1
+	}
+
+	return k
+}
+
+// tfReader read packed term frequencies of posting list in order of
+// postings.
+type tfReader struct {
+	packed []byte
+	// Position of the next posting with frequency great than 1 or -1.
+	next, tf int
+}
+
+// Read the next frequency after posting at position.
+func (r *tfReader) read(position int) {
+	r.next = -1
+
+	delta, n := binary.Uvarint(r.packed)
+	if n <= 0 {
+		return
+	}
+
+	tf, m := binary.Uvarint(r.packed[n:])
+	if m <= 0 {
+		return
+	}
+
+	r.packed = r.packed[n+m:]
+	r.next, r.tf = position+int(delta), int(tf)
+}
+
+// Frequency of posting at position. Positions should ascend, but may be
+// skipped.
+func (r *tfReader) at(position int) int {
+	for r.next >= 0 && r.next < position {
+		r.read(r.next)
+	}
+
+	if position != r.next {
+		return 1
+	}
+
+	tf := r.tf
+	r.read(position)
+
+	return tf
+}
+
+func (index *DiskIndex) frequency(ngram string) int {
+	k := index.gram(ngram)
+	if k < 0 {
+		return 0
+	}
+
+	return int(binary.LittleEndian.Uint32(index.data[index.header[diskCountsField]+4*k:]))
+}
+
+func (index *DiskIndex) total() int {
+	return index.header[diskStringsField]
+}
+
+func (index *DiskIndex) liveTotal() int {
+	return index.header[diskLiveField]
+}
+
+func (index *DiskIndex) avgSize() float64 {
+	return index.avg
+}
+
+func (index *DiskIndex) norm(i int) float64 {
+	if offset := index.header[diskNormsField]; offset != 0 {
+		return math.Float64frombits(binary.LittleEndian.Uint64(index.data[offset+8*i:]))
+	}
+
+	return index.norms[i]
+}
+
+func (index *DiskIndex) size(i int) int {
+	return int(binary.LittleEndian.Uint32(index.data[index.header[diskSizesField]+4*i:]))
+}
+
+func (index *DiskIndex) text(i int) string {
+	return string(index.slice(diskStringOffsetsField, diskStringDataField, i))
+}
+
+func (index *DiskIndex) payload(i int) interface{} {
+	if index.payloads == nil {
+		return nil
+	}
+
+	return index.payloads[i]
+}
+
+func (index *DiskIndex) removed(i int) bool {
+	return index.data[index.header[diskRemovedField]+i] != 0
+}
+
+// Return k-th item of data section by offsets table.
+func (index *DiskIndex) slice(table, data, k int) []byte {
+	t, d := index.header[table], index.header[data]
+	start, end := index.uint64(t+8*k), index.uint64(t+8*k+8)
+
+	return index.data[d+start : d+end]
+}
+
+func (index *DiskIndex) uint64(offset int) int {
+	return int(binary.LittleEndian.Uint64(index.data[offset:]))
+}
@@ -0,0 +1,321 @@
+package muzzy_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vporoshok/muzzy"
+)
+
+func writeDiskIndex(t *testing.T, index *muzzy.SplitIndex) string {
+	f, err := ioutil.TempFile("", "muzzy")
+	require.NoError(t, err)
+
+	defer f.Close()
+
+	_, err = index.WriteDisk(f)
+	require.NoError(t, err)
+
+	return f.Name()
+}
+
+func TestDiskIndex(t *testing.T) {
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true))
+	index.Add(readCorpusLines(t)...)
+	index.Remove(0)
+
+	path := writeDiskIndex(t, index)
+	defer os.Remove(path)
+
+	disk, err := muzzy.OpenDiskIndex(path, nil)
+	require.NoError(t, err)
+
+	defer disk.Close()
+
+	queries := [...]string{
+		`"Что ж баирн? у себя, что ли?"`,
+		`"Что ж барин? у себя, что ли?"`,
+		"Николай Васильевич Гоголь",
+		"Чичиков",
+		"not found",
+	}
+
+	for _, query := range queries {
+		expected, ok := index.Find(query)
+		actual, _ := disk.Find(query)
+		assert.Equal(t, expected, actual, query)
+		assert.Equal(t, ok, expected.Index >= 0, query)
+		assert.Equal(t, index.Search(query), disk.Search(query), query)
+		assert.Equal(t, index.SearchTopK(query, 10), disk.SearchTopK(query, 10), query)
+		assert.Equal(t, index.SearchThreshold(query, 0.3), disk.SearchThreshold(query, 0.3), query)
+		assert.Equal(t,
+			index.SearchRerank(query, 10, muzzy.Jaro, 0.5),
+			disk.SearchRerank(query, 10, muzzy.Jaro, 0.5),
+			query,
+		)
+	}
+
+	assert.Equal(t, "", disk.Get(0))
+	assert.Equal(t, index.Get(10), disk.Get(10))
+	assert.Equal(t, "", disk.Get(-1))
+	assert.Empty(t, disk.SearchTopK("Чичиков", 0))
+
+	hits, err := disk.SearchTopKContext(context.Background(), "Чичиков", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, disk.SearchTopK("Чичиков", 10), hits)
+
+	hits, err = disk.SearchRerankContext(context.Background(), "Чичиков", 10, muzzy.Jaro, 0.5)
+	assert.NoError(t, err)
+	assert.Equal(t, disk.SearchRerank("Чичиков", 10, muzzy.Jaro, 0.5), hits)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = disk.SearchThresholdContext(ctx, "Чичиков", 0.3)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = disk.SearchRerankContext(ctx, "Чичиков", 10, muzzy.Jaro, 0.5)
+	assert.Equal(t, context.Canceled, err)
+	assert.NoError(t, disk.Close())
+}
+
+func TestDiskIndexRemoved(t *testing.T) {
+	lines := readCorpusLines(t)
+	splitter := muzzy.NGramSplitter(3, true)
+	model := muzzy.NewIDFModel(splitter)
+	model.Fit(lines...)
+
+	options := map[string][]muzzy.IndexOption{
+		"StopGrams":    {muzzy.WithStopGrams(0.05)},
+		"BM25":         {muzzy.WithBM25(muzzy.DefaultBM25K1, muzzy.DefaultBM25B)},
+		"IDFStopGrams": {muzzy.WithIDF(model), muzzy.WithStopGrams(0.02)},
+		"Normalizer":   {muzzy.WithNormalizer(strings.ToUpper)},
+	}
+
+	for name, opts := range options {
+		index := muzzy.NewSplitIndex(splitter, opts...)
+		index.Add(lines...)
+
+		for i := 0; i < len(lines); i += 3 {
+			index.Remove(i)
+		}
+
+		path := writeDiskIndex(t, index)
+		defer os.Remove(path)
+
+		disk, err := muzzy.OpenDiskIndex(path, nil, opts...)
+		require.NoError(t, err)
+
+		defer disk.Close()
+
+		for i := 4; i < len(lines); i += 18 {
+			query := lines[i]
+			expected, _ := index.Find(query)
+			actual, _ := disk.Find(query)
+			assert.Equal(t, expected, actual, "%s %q", name, query)
+
+			expected, _ = index.Find(strings.ToUpper(query))
+			actual, _ = disk.Find(strings.ToUpper(query))
+			assert.Equal(t, expected, actual, "%s %q", name, query)
+			assert.Equal(t, index.SearchTopK(query, 10), disk.SearchTopK(query, 10), "%s %q", name, query)
+			assert.Equal(t, index.SearchThreshold(query, 0.3), disk.SearchThreshold(query, 0.3), "%s %q", name, query)
+		}
+
+		frequencies := index.GramFrequencies()
+		index.Compact()
+		assert.Equal(t, frequencies, index.GramFrequencies(), name)
+	}
+}
+
+func TestDiskIndexNormalizer(t *testing.T) {
+	option := muzzy.WithNormalizer(strings.ToLower)
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true), option)
+	index.Add("milk", "silk", "milk")
+	index.Remove(0)
+
+	path := writeDiskIndex(t, index)
+	defer os.Remove(path)
+
+	disk, err := muzzy.OpenDiskIndex(path, nil, option)
+	require.NoError(t, err)
+
+	defer disk.Close()
+
+	// Query shares no n-grams with the string, but they are equal after
+	// normalization.
+	expected, ok := index.Find("MILK")
+	require.True(t, ok)
+	assert.Equal(t, muzzy.Hit{Index: 2, String: "milk", Exact: true}, expected)
+
+	actual, ok := disk.Find("MILK")
+	assert.True(t, ok)
+	assert.Equal(t, expected, actual)
+
+	_, ok = disk.Find("CHEESE")
+	assert.False(t, ok)
+}
+
+func TestDiskIndexPayload(t *testing.T) {
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true))
+	index.Add("milk")
+	index.AddWithPayload("silk", 42)
+	index.AddWithPayload("happiness", "key")
+	index.AddWithPayload("princess", "removed")
+	index.Remove(3)
+
+	path := writeDiskIndex(t, index)
+	defer os.Remove(path)
+
+	disk, err := muzzy.OpenDiskIndex(path, nil)
+	require.NoError(t, err)
+
+	defer disk.Close()
+
+	assert.Nil(t, disk.Payload(0))
+	assert.Equal(t, 42, disk.Payload(1))
+	assert.Equal(t, "key", disk.Payload(2))
+	assert.Nil(t, disk.Payload(3))
+	assert.Nil(t, disk.Payload(10))
+	assert.Equal(t, index.SearchTopK("silk", 3), disk.SearchTopK("silk", 3))
+
+	type unregistered struct{ ID int }
+
+	index.AddWithPayload("princess", unregistered{1})
+
+	_, err = index.WriteDisk(ioutil.Discard)
+	assert.Error(t, err)
+}
+
+func TestDiskIndexRanking(t *testing.T) {
+	splits := 0
+	splitter := muzzy.SplitterFunc(func(s string) []string {
+		splits++
+
+		return strings.Fields(s)
+	})
+
+	ss := []string{"red milk", "white silk", "red red wine", "white wine", "milk milk milk"}
+	model := muzzy.NewIDFModel(splitter)
+	model.Fit(ss...)
+
+	options := map[string][]muzzy.IndexOption{
+		"IDF":  {muzzy.WithIDF(model)},
+		"BM25": {muzzy.WithBM25(muzzy.DefaultBM25K1, muzzy.DefaultBM25B)},
+	}
+
+	for name, opts := range options {
+		index := muzzy.NewSplitIndex(splitter, opts...)
+		index.Add(ss...)
+		index.Remove(1)
+
+		path := writeDiskIndex(t, index)
+		defer os.Remove(path)
+
+		// Ranking data is read from file, so strings are not split on open.
+		splits = 0
+		disk, err := muzzy.OpenDiskIndex(path, splitter, opts...)
+		require.NoError(t, err)
+		assert.Equal(t, 0, splits, name)
+
+		defer disk.Close()
+
+		for _, query := range [...]string{"red wine", "milk", "white silk"} {
+			assert.Equal(t, index.SearchTopK(query, 5), disk.SearchTopK(query, 5), "%s %q", name, query)
+		}
+	}
+
+	// Index without IDF model has no norms, so they are calculated on open.
+	index := muzzy.NewSplitIndex(splitter)
+	index.Add(ss...)
+
+	path := writeDiskIndex(t, index)
+	defer os.Remove(path)
+
+	disk, err := muzzy.OpenDiskIndex(path, splitter, muzzy.WithIDF(model))
+	require.NoError(t, err)
+
+	defer disk.Close()
+
+	withIDF := muzzy.NewSplitIndex(splitter, muzzy.WithIDF(model))
+	withIDF.Add(ss...)
+	assert.Equal(t, withIDF.SearchTopK("red wine", 5), disk.SearchTopK("red wine", 5))
+}
+
+func TestDiskIndexErrors(t *testing.T) {
+	index := muzzy.NewSplitIndex(muzzy.SplitterFunc(strings.Fields))
+	index.Add("red milk", "white silk")
+
+	path := writeDiskIndex(t, index)
+	defer os.Remove(path)
+
+	_, err := muzzy.OpenDiskIndex(path, nil)
+	assert.Equal(t, muzzy.ErrSplitterMismatch, err)
+
+	disk, err := muzzy.OpenDiskIndex(path, muzzy.SplitterFunc(strings.Fields))
+	require.NoError(t, err)
+	assert.Equal(t, 1, disk.Search("silk"))
+	assert.NoError(t, disk.Close())
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	// Version is the first header field after magic string.
+	old := append([]byte(nil), data...)
+	old[len("muzzydsk")] = 1
+	require.NoError(t, ioutil.WriteFile(path, old, 0600))
+
+	_, err = muzzy.OpenDiskIndex(path, muzzy.SplitterFunc(strings.Fields))
+	assert.EqualError(t, err, "muzzy: unsupported disk index version 1")
+
+	for _, n := range [...]int{0, 10, len(data) / 2, len(data) - 1} {
+		require.NoError(t, ioutil.WriteFile(path, data[:n], 0600))
+
+		_, err = muzzy.OpenDiskIndex(path, muzzy.SplitterFunc(strings.Fields))
+		assert.Equal(t, muzzy.ErrInvalidFormat, err, "truncated to %d", n)
+	}
+
+	// Posting list of "a" is 0, 1, ..., 9 packed as deltas 0, 1, ..., 1. Delta
+	// 1<<63 overflows the string index.
+	index = muzzy.NewSplitIndex(muzzy.SplitterFunc(strings.Fields))
+	for i := 0; i < 10; i++ {
+		index.Add(fmt.Sprintf("a %d", i))
+	}
+
+	var buf bytes.Buffer
+
+	_, err = index.WriteDisk(&buf)
+	require.NoError(t, err)
+
+	postings := []byte{0, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+	overflow := make([]byte, binary.MaxVarintLen64)
+	require.Len(t, overflow[:binary.PutUvarint(overflow, 1<<63)], len(postings))
+
+	data = buf.Bytes()
+	k := bytes.LastIndex(data, postings)
+	require.True(t, k >= 0)
+	copy(data[k:], overflow)
+	require.NoError(t, ioutil.WriteFile(path, data, 0600))
+
+	disk, err = muzzy.OpenDiskIndex(path, muzzy.SplitterFunc(strings.Fields))
+	require.NoError(t, err)
+
+	hits := disk.SearchTopK("a 2", 10)
+	if assert.Len(t, hits, 1) {
+		assert.Equal(t, 2, hits[0].Index)
+	}
+
+	assert.NoError(t, disk.Close())
+
+	_, err = muzzy.OpenDiskIndex(filepath.Join("testdata", "not_exists"), nil)
+	assert.Error(t, err)
+}
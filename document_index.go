@@ -0,0 +1,166 @@
+package muzzy
+
+import (
+	"sort"
+	"sync"
+)
+
+// Document is a set of named text fields.
+type Document map[string]string
+
+// DocumentHit is a document search result.
+type DocumentHit struct {
+	// Index of the document in index.
+	Index int
+	// Document is the indexed document.
+	Document Document
+	// Score is a weighted similarity of the document to the query.
+	Score float64
+	// Fields contain similarity of every queried field.
+	Fields map[string]float64
+}
+
+// DocumentIndex index to search documents with several text fields
+//
+// Every field has its own posting lists. Query fields are searched
+// separately and field scores are combined as weighted mean, so score of the
+// document is between 0 and 1. DocumentIndex is safe for concurrent use.
+type DocumentIndex struct {
+	mu      sync.RWMutex
+	weights map[string]float64
+	fields  map[string]*SplitIndex
+	docs    []Document
+}
+
+// NewDocumentIndex is a constructor
+//
+// Indexed fields are the keys of weights, other fields of documents are kept
+// but not indexed. Every field is indexed with the same splitter and options.
+func NewDocumentIndex(splitter Splitter, weights map[string]float64, options ...IndexOption) *DocumentIndex {
+	index := &DocumentIndex{
+		weights: make(map[string]float64, len(weights)),
+		fields:  make(map[string]*SplitIndex, len(weights)),
+	}
+
+	for field, weight := range weights {
+		index.weights[field] = weight
+		index.fields[field] = NewSplitIndex(splitter, options...)
+	}
+
+	return index
+}
+
+// Add documents to index.
+func (index *DocumentIndex) Add(docs ...Document) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	for field, fieldIndex := range index.fields {
+		values := make([]string, len(docs))
+		missing := make([]bool, len(docs))
+
+		for i, doc := range docs {
+			value, ok := doc[field]
+			values[i], missing[i] = value, !ok
+		}
+
+		// Missing fields take indexes as removed strings to keep indexes of
+		// documents the same in every field index, but they are not counted in
+		// frequencies and sizes of field strings.
+		fieldIndex.addMissing(values, missing)
+	}
+
+	index.docs = append(index.docs, docs...)
+}
+
+// Get document by index.
+func (index *DocumentIndex) Get(i int) Document {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	if i < 0 || i >= len(index.docs) {
+		return nil
+	}
+
+	return index.docs[i]
+}
+
+// Search return up to k documents most similar to query
+//
+// Only not empty indexed fields of query are searched. Score of document is
+// a weighted mean of fields similarities, fields without shared n-grams have
+// zero similarity. Hits are sorted as in SplitIndex.
+func (index *DocumentIndex) Search(query Document, k int) []DocumentHit {
+	if k <= 0 {
+		return nil
+	}
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	fields := make([]string, 0, len(query))
+	total := 0.0
+
+	for field, s := range query {
+		if weight, ok := index.weights[field]; ok && weight > 0 && s != "" {
+			fields = append(fields, field)
+			total += weight
+		}
+	}
+
+	sort.Strings(fields)
+
+	// Field scores are summed in sorted order of fields, so score does not
+	// depend on map iteration order.
+	fieldScores := make([]map[int]float64, len(fields))
+	sums := map[int]float64{}
+
+	for j, field := range fields {
+		fieldScores[j] = index.fields[field].scores(query[field])
+
+		for i, score := range fieldScores[j] {
+			sums[i] += index.weights[field] * score
+		}
+	}
+
+	top := make(hitHeap, 0, min(k, len(sums)))
+
+	for i, sum := range sums {
+		top.Offer(Hit{Index: i, Score: sum / total}, k)
+	}
+
+	sortHits(top)
+
+	hits := make([]DocumentHit, len(top))
+	for j, hit := range top {
+		hits[j] = DocumentHit{
+			Index:    hit.Index,
+			Document: index.docs[hit.Index],
+			Score:    hit.Score,
+			Fields:   map[string]float64{},
+		}
+
+		for f, field := range fields {
+			if score, ok := fieldScores[f][hit.Index]; ok {
+				hits[j].Fields[field] = score
+			}
+		}
+	}
+
+	return hits
+}
+
+// SearchField return up to k documents with field most similar to s.
+func (index *DocumentIndex) SearchField(field, s string, k int) []DocumentHit {
+	return index.Search(Document{field: s}, k)
+}
+
+// SearchAll return up to k documents most similar to s in all fields.
+func (index *DocumentIndex) SearchAll(s string, k int) []DocumentHit {
+	query := make(Document, len(index.weights))
+	for field := range index.weights {
+		query[field] = s
+	}
+
+	return index.Search(query, k)
+}
@@ -0,0 +1,83 @@
+package muzzy_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vporoshok/muzzy"
+)
+
+func TestDocumentIndex(t *testing.T) {
+	splitter := muzzy.NGramSplitter(3, true)
+	index := muzzy.NewDocumentIndex(splitter, map[string]float64{
+		"name":   2,
+		"city":   1,
+		"street": 1,
+	})
+	index.Add(
+		muzzy.Document{"name": "Pavel Chichikov", "city": "NN", "street": "Gostinaya"},
+		muzzy.Document{"name": "Nikolai Manilov", "city": "Manilovka", "street": "Prudovaya"},
+		muzzy.Document{"name": "Nastasya Korobochka", "city": "Korobochkino", "id": "42"},
+		muzzy.Document{"name": "Pavel Manilov", "city": "Manilovka", "street": "Gostinaya"},
+	)
+
+	hits := index.Search(muzzy.Document{"name": "Pavel Chichikov", "street": "Gostinaya"}, 2)
+	if assert.Len(t, hits, 2) {
+		assert.Equal(t, 0, hits[0].Index)
+		assert.InDelta(t, 1, hits[0].Score, 1e-9)
+		assert.Equal(t, 3, hits[1].Index)
+
+		name := splitter.Similarity("Pavel Chichikov", "Pavel Manilov")
+		assert.InDelta(t, name, hits[1].Fields["name"], 1e-9)
+		assert.InDelta(t, 1, hits[1].Fields["street"], 1e-9)
+		assert.InDelta(t, (2*name+1)/3, hits[1].Score, 1e-9)
+	}
+
+	hits = index.SearchField("city", "Manilovka", 5)
+	if assert.Len(t, hits, 2) {
+		assert.Equal(t, 1, hits[0].Index)
+		assert.Equal(t, 3, hits[1].Index)
+		assert.Equal(t, "Prudovaya", hits[0].Document["street"])
+	}
+
+	hits = index.SearchAll("Korobochka", 1)
+	if assert.Len(t, hits, 1) {
+		assert.Equal(t, 2, hits[0].Index)
+		assert.Equal(t, "42", hits[0].Document["id"])
+	}
+
+	assert.Empty(t, index.SearchField("street", "Prudovaya", 0))
+	assert.Empty(t, index.SearchField("id", "42", 5))
+	assert.Empty(t, index.Search(muzzy.Document{"name": "zzz"}, 5))
+	assert.Equal(t, "Pavel Manilov", index.Get(3)["name"])
+	assert.Nil(t, index.Get(4))
+}
+
+func TestDocumentIndexMissingField(t *testing.T) {
+	splitter := muzzy.NGramSplitter(3, true)
+	option := muzzy.WithBM25(muzzy.DefaultBM25K1, muzzy.DefaultBM25B)
+	index := muzzy.NewDocumentIndex(splitter, map[string]float64{"name": 1, "street": 1}, option)
+	index.Add(
+		muzzy.Document{"name": "Pavel Chichikov", "street": "Gostinaya"},
+		muzzy.Document{"name": "Nastasya Korobochka"},
+		muzzy.Document{"name": "Nikolai Manilov", "street": "Prudovaya"},
+	)
+
+	// Missing field is counted neither in number nor in sizes of strings.
+	streets := muzzy.NewSplitIndex(splitter, option)
+	streets.Add("Gostinaya", "Prudovaya")
+
+	expected := streets.SearchTopK("Gostinaya", 5)
+	hits := index.SearchField("street", "Gostinaya", 5)
+
+	if assert.Len(t, hits, len(expected)) {
+		for j, hit := range hits {
+			assert.Equal(t, 2*expected[j].Index, hit.Index)
+			assert.InDelta(t, expected[j].Score, hit.Fields["street"], 1e-9)
+		}
+	}
+
+	assert.Empty(t, index.SearchField("street", "", 5))
+	assert.Equal(t, 1, index.SearchField("name", "Korobochka", 1)[0].Index)
+}
@@ -0,0 +1,367 @@
+package muzzy
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Explanation describe how score of indexed string is built.
+type Explanation struct {
+	// Index of the string in index.
+	Index int
+	// String is the indexed string.
+	String string
+	// Grams are n-grams of the query sorted lexicographically.
+	Grams []GramExplanation
+	// Common is a number of query n-grams, except stop-grams, contained in
+	// the string.
+	Common int
+	// QuerySize is a number of n-grams of the query except stop-grams.
+	QuerySize int
+	// Size is a number of n-grams of the string.
+	Size int
+	// Weight is a sum of weights of common n-grams with IDF model or BM25.
+	Weight float64
+	// QueryNorm and Norm are IDF norms of the query and the string with IDF
+	// model.
+	QueryNorm, Norm float64
+	// Score is an index coefficient of Common, QuerySize and Size, Weight
+	// divided by product of norms with IDF model or Weight with BM25.
+	Score float64
+	// Exact is true if normalized string is equal to normalized query.
+	Exact bool
+}
+
+// GramExplanation describe one n-gram of the query.
+type GramExplanation struct {
+	// Gram is the n-gram.
+	Gram string
+	// Matched is true if the string contain the n-gram.
+	Matched bool
+	// Stop is true if the n-gram is excluded from the query as a stop-gram.
+	Stop bool
+	// Frequency is a number of indexed strings containing the n-gram.
+	Frequency int
+	// IDF of the n-gram with IDF model or BM25.
+	IDF float64
+	// Weight of matched n-gram: squared IDF with IDF model or BM25 weight of
+	// the n-gram in the string.
+	Weight float64
+}
+
+// Describe format explanation as several lines of text.
+func (e Explanation) Describe() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%d %q: %d of %d query n-grams in %d n-grams of string\n",
+		e.Index, e.String, e.Common, e.QuerySize, e.Size)
+
+	for _, gram := range e.Grams {
+		status := "miss"
+		if gram.Matched {
+			status = "hit"
+		}
+
+		if gram.Stop {
+			status += " (stop)"
+		}
+
+		fmt.Fprintf(&b, "  %q %s, in %d strings", gram.Gram, status, gram.Frequency)
+
+		if gram.IDF > 0 {
+			fmt.Fprintf(&b, ", idf %.4f", gram.IDF)
+		}
+
+		if gram.Weight > 0 {
+			fmt.Fprintf(&b, ", weight %.4f", gram.Weight)
+		}
+
+		b.WriteByte('\n')
+	}
+
+	switch {
+	case e.QueryNorm > 0:
+		fmt.Fprintf(&b, "score = %.4f / (%.4f * %.4f) = %.4f", e.Weight, e.QueryNorm, e.Norm, e.Score)
+	case e.Weight > 0 && e.Weight == e.Score:
+		fmt.Fprintf(&b, "score = sum of weights = %.4f", e.Score)
+	default:
+		fmt.Fprintf(&b, "score = coefficient(%d, %d, %d) = %.4f", e.Common, e.QuerySize, e.Size, e.Score)
+	}
+
+	if e.Exact {
+		b.WriteString(", exact")
+	}
+
+	return b.String()
+}
+
+// Explain how score of i-th string for query s is built
+//
+// Return false if there is no such string. Score is the same as in search
+// results.
+func (index *SplitIndex) Explain(s string, i int) (Explanation, bool) {
+	ngrams := index.Split(s)
+	q := index.query(s, ngrams)
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	if !index.alive(i) {
+		return Explanation{}, false
+	}
+
+	return q.explain(i), true
+}
+
+// Explain how score of i-th string for query s is built.
+func (index *DiskIndex) Explain(s string, i int) (Explanation, bool) {
+	if i < 0 || i >= index.header[diskStringsField] || index.removed(i) {
+		return Explanation{}, false
+	}
+
+	return index.query(s).explain(i), true
+}
+
+func (q *query) explain(i int) Explanation {
+	q.prepare()
+
+	e := Explanation{
+		Index:     i,
+		String:    q.source.text(i),
+		Grams:     make([]GramExplanation, 0, len(q.ngrams)+len(q.stop)),
+		QuerySize: len(q.ngrams),
+		Size:      q.source.size(i),
+	}
+
+	for _, ngram := range q.ngrams {
+		e.Grams = append(e.Grams, GramExplanation{Gram: ngram})
+	}
+
+	for _, ngram := range q.stop {
+		e.Grams = append(e.Grams, GramExplanation{Gram: ngram, Stop: true})
+	}
+
+	for j := range e.Grams {
+		gram := &e.Grams[j]
+
+		tf := 0
+
+		q.source.eachPosting(gram.Gram, func(k, n int) bool {
+			gram.Frequency++
+
+			if k == i {
+				gram.Matched, tf = true, n
+			}
+
+			return true
+		})
+
+		switch {
+		case q.bm25 != nil:
+			gram.IDF = q.weight(gram.Gram)
+		case q.idf != nil:
+			gram.IDF = q.idf.IDF(gram.Gram)
+		}
+
+		if gram.Matched && !gram.Stop {
+			e.Common++
+
+			if q.idf != nil || q.bm25 != nil {
+				gram.Weight = q.postingWeight(q.weight(gram.Gram), i, tf)
+				e.Weight += gram.Weight
+			}
+		}
+	}
+
+	sort.Slice(e.Grams, func(a, b int) bool {
+		return e.Grams[a].Gram < e.Grams[b].Gram
+	})
+
+	switch {
+	case q.bm25 != nil:
+		e.Score = q.score(i, e.Weight)
+	case q.idf != nil:
+		e.QueryNorm, e.Norm = q.queryNorm, q.source.norm(i)
+		e.Score = q.score(i, e.Weight)
+	default:
+		e.Score = q.score(i, float64(e.Common))
+	}
+	e.Exact = q.normalizeString(e.String) == q.normalized
+
+	return e
+}
+
+// SimilarityExplanation describe how Similarity of two strings is built.
+//
+// Fields not used by algorithm are zero.
+type SimilarityExplanation struct {
+	// Algorithm of similarity.
+	Algorithm similarityAlgorithm
+	// Len1 and Len2 are lengths of strings: in bytes for Levenshtein
+	// algorithms, in runes for Jaro algorithms and in n-grams for NGram.
+	Len1, Len2 int
+	// Distance is an edit distance of Levenshtein algorithms or -1, if it is
+	// great than Bound.
+	Distance int
+	// Bound of distance given by threshold.
+	Bound int
+	// Matches is a number of matched runes of Jaro algorithms.
+	Matches int
+	// Transpositions is a number of matched runes out of order.
+	Transpositions int
+	// Prefix is a length of common prefix of Jaro-Winkler algorithm.
+	Prefix int
+	// Common is a number of shared n-grams of NGram algorithm.
+	Common int
+	// Raw is a similarity before threshold is applied.
+	Raw float64
+	// Threshold of similarity.
+	Threshold float64
+	// Score is a result of Similarity.
+	Score float64
+}
+
+// ExplainSimilarity return Similarity of two strings with intermediate values
+// of algorithm.
+func ExplainSimilarity(s1, s2 string, algo similarityAlgorithm, threshold float64) SimilarityExplanation {
+	e := SimilarityExplanation{Algorithm: algo, Threshold: threshold}
+
+	switch {
+	case s1 == "":
+		if s2 == "" {
+			e.Raw = 1
+		}
+
+	case algo == Levenshtein || algo == DamerauLevenshtein:
+		e.explainLevenshtein(s1, s2)
+
+	case algo == Jaro || algo == JaroWinkler:
+		e.explainJaro(s1, s2)
+
+	default:
+		splitter := NGramSplitter(defaultNGramSize, true)
+		grams1, grams2 := splitter.Split(s1), splitter.Split(s2)
+		e.Len1, e.Len2 = len(grams1), len(grams2)
+		e.Common = commonGrams(grams1, grams2)
+		e.Raw = OtsukaOchiai(e.Common, e.Len1, e.Len2)
+	}
+
+	if e.Raw >= threshold {
+		e.Score = e.Raw
+	}
+
+	return e
+}
+
+func (e *SimilarityExplanation) explainLevenshtein(s1, s2 string) {
+	e.Len1, e.Len2 = len(s1), len(s2)
+	max := math.Max(float64(len(s1)), float64(len(s2)))
+	e.Bound = int(math.Floor((1 - e.Threshold) * max))
+
+	if e.Algorithm == Levenshtein {
+		e.Distance = LevenshteinDistance(s1, s2, e.Bound)
+	} else {
+		e.Distance = DamerauDistance(s1, s2, e.Bound)
+	}
+
+	if e.Distance >= 0 {
+		e.Raw = 1 - float64(e.Distance)/max
+	}
+}
+
+func (e *SimilarityExplanation) explainJaro(s1, s2 string) {
+	jc := newJaroCalculator(s1, s2)
+	e.Len1, e.Len2 = len(jc.s1), len(jc.s2)
+
+	m := jc.FindMatchesCartesian()
+	if m > 0 {
+		t := jc.FindTranspositions()
+		e.Matches, e.Transpositions = int(m), int(t)
+		e.Raw = jc.Similarity(m, t)
+	}
+
+	if e.Algorithm == JaroWinkler {
+		e.Prefix = commonPrefix(s1, s2)
+		e.Raw = winkler(e.Raw, e.Prefix)
+	}
+}
+
+// Count n-grams of the first set contained in the second one.
+func commonGrams(grams1, grams2 []string) int {
+	set := make(map[string]struct{}, len(grams2))
+	for _, gram := range grams2 {
+		set[gram] = struct{}{}
+	}
+
+	common := 0
+
+	for _, gram := range grams1 {
+		if _, ok := set[gram]; ok {
+			common++
+		}
+	}
+
+	return common
+}
+
+// String format arithmetic of similarity.
+func (e SimilarityExplanation) String() string {
+	var s string
+
+	switch {
+	case e.Len1 == 0 && e.Len2 == 0 && e.Raw == 1:
+		s = "both strings are empty: 1"
+
+	case e.Algorithm == Levenshtein || e.Algorithm == DamerauLevenshtein:
+		s = e.levenshteinString()
+
+	case e.Algorithm == Jaro || e.Algorithm == JaroWinkler:
+		s = e.jaroString()
+
+	default:
+		s = fmt.Sprintf("%d common of %d and %d n-grams: %d/sqrt(%d*%d) = %.4f",
+			e.Common, e.Len1, e.Len2, e.Common, e.Len1, e.Len2, e.Raw)
+	}
+
+	if e.Raw < e.Threshold {
+		s += fmt.Sprintf(", below threshold %g: 0", e.Threshold)
+	}
+
+	return s
+}
+
+func (e SimilarityExplanation) levenshteinString() string {
+	if e.Distance < 0 {
+		return fmt.Sprintf("distance exceed bound %d: 0", e.Bound)
+	}
+
+	max := e.Len1
+	if e.Len2 > max {
+		max = e.Len2
+	}
+
+	return fmt.Sprintf("distance %d (bound %d) normalized by length %d: 1 - %d/%d = %.4f",
+		e.Distance, e.Bound, max, e.Distance, max, e.Raw)
+}
+
+func (e SimilarityExplanation) jaroString() string {
+	if e.Matches == 0 && e.Algorithm == Jaro {
+		return "no matches: 0"
+	}
+
+	s := "no matches: 0"
+
+	if e.Matches > 0 {
+		m := e.Matches
+		s = fmt.Sprintf("%d matches, %d transpositions of %d and %d runes: (%d/%d + %d/%d + (%d-%d)/%d) / 3",
+			m, e.Transpositions, e.Len1, e.Len2, m, e.Len1, m, e.Len2, m, e.Transpositions, m)
+	}
+
+	if e.Algorithm == JaroWinkler {
+		s += fmt.Sprintf(", common prefix %d: jaro + %d*(1-jaro)*%g", e.Prefix, e.Prefix, WinklerScalingFactor)
+	}
+
+	return fmt.Sprintf("%s = %.4f", s, e.Raw)
+}
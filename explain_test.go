@@ -0,0 +1,113 @@
+package muzzy_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vporoshok/muzzy"
+)
+
+func TestSplitIndexExplain(t *testing.T) {
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true))
+	index.Add("milk", "silk", "happiness", "mild")
+
+	hits := index.SearchTopK("mil", 10)
+	require.NotEmpty(t, hits)
+
+	for _, hit := range hits {
+		e, ok := index.Explain("mil", hit.Index)
+		require.True(t, ok)
+		assert.Equal(t, hit.String, e.String)
+		assert.Equal(t, hit.Score, e.Score)
+		assert.Equal(t, hit.Exact, e.Exact)
+	}
+
+	e, ok := index.Explain("mil", 0)
+	require.True(t, ok)
+	assert.Equal(t, []muzzy.GramExplanation{
+		{Gram: "  m", Matched: true, Frequency: 2},
+		{Gram: " mi", Matched: true, Frequency: 2},
+		{Gram: "il ", Matched: false, Frequency: 0},
+		{Gram: "l  ", Matched: false, Frequency: 0},
+		{Gram: "mil", Matched: true, Frequency: 2},
+	}, e.Grams)
+	assert.Equal(t, 3, e.Common)
+	assert.Equal(t, 5, e.QuerySize)
+	assert.Equal(t, 6, e.Size)
+	assert.Contains(t, e.Describe(), "score = coefficient(3, 5, 6) = 0.5477")
+
+	e, ok = index.Explain("mil", 2)
+	require.True(t, ok)
+	assert.Equal(t, 0, e.Common)
+	assert.Equal(t, 0.0, e.Score)
+
+	index.Remove(3)
+
+	e, _ = index.Explain("mil", 0)
+	assert.Equal(t, 1, e.Grams[0].Frequency)
+
+	_, ok = index.Explain("mil", 3)
+	assert.False(t, ok)
+	_, ok = index.Explain("mil", 4)
+	assert.False(t, ok)
+
+	path := writeDiskIndex(t, index)
+	defer os.Remove(path)
+
+	disk, err := muzzy.OpenDiskIndex(path, nil)
+	require.NoError(t, err)
+
+	defer disk.Close()
+
+	actual, ok := disk.Explain("mil", 0)
+	assert.True(t, ok)
+	assert.Equal(t, e, actual)
+
+	_, ok = disk.Explain("mil", 3)
+	assert.False(t, ok)
+}
+
+func TestExplainSimilarity(t *testing.T) {
+	pairs := [...][2]string{
+		{"happiness", "princess"},
+		{"fluffy", "fulffy"},
+		{"", ""},
+		{"", "any"},
+		{"abcde", "fghij"},
+	}
+
+	for algo := muzzy.Levenshtein; algo <= muzzy.NGram; algo++ {
+		for _, pair := range pairs {
+			for _, threshold := range [...]float64{0, 0.7} {
+				e := muzzy.ExplainSimilarity(pair[0], pair[1], algo, threshold)
+				assert.Equal(t, muzzy.Similarity(pair[0], pair[1], algo, threshold), e.Score, "%v %v", algo, pair)
+			}
+		}
+	}
+
+	e := muzzy.ExplainSimilarity("happiness", "princess", muzzy.Levenshtein, 0)
+	assert.Equal(t, 4, e.Distance)
+	assert.Equal(t, 9, e.Bound)
+	assert.Equal(t, "distance 4 (bound 9) normalized by length 9: 1 - 4/9 = 0.5556", e.String())
+
+	e = muzzy.ExplainSimilarity("happiness", "princess", muzzy.DamerauLevenshtein, 0.9)
+	assert.Equal(t, -1, e.Distance)
+	assert.Equal(t, "distance exceed bound 0: 0, below threshold 0.9: 0", e.String())
+
+	e = muzzy.ExplainSimilarity("fluffy", "fulffy", muzzy.JaroWinkler, 0)
+	assert.Equal(t, 6, e.Matches)
+	assert.Equal(t, 2, e.Transpositions)
+	assert.Equal(t, 1, e.Prefix)
+	assert.True(t, strings.HasSuffix(e.String(), "= 0.9000"), e.String())
+
+	e = muzzy.ExplainSimilarity("happiness", "princess", muzzy.NGram, 0)
+	assert.Equal(t, 3, e.Common)
+	assert.Equal(t, "3 common of 11 and 10 n-grams: 3/sqrt(11*10) = 0.2860", e.String())
+
+	assert.Equal(t, "no matches: 0", muzzy.ExplainSimilarity("abc", "xyz", muzzy.Jaro, 0).String())
+	assert.Equal(t, "both strings are empty: 1", muzzy.ExplainSimilarity("", "", muzzy.Jaro, 0).String())
+}
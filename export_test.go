@@ -0,0 +1,13 @@
+package muzzy
+
+// SearchTopKExhaustive is a SearchTopK, that score every string sharing
+// n-grams with the query. It is exported to compare MaxScore search with.
+func (index *SplitIndex) SearchTopKExhaustive(s string, k int) []Hit {
+	ngrams := index.Split(s)
+	q := index.query(s, ngrams)
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	return q.rank(q.count(), k)
+}
@@ -0,0 +1,136 @@
+package muzzy
+
+import (
+	"math"
+	"sort"
+)
+
+// IDFModel is a splitter with TF-IDF cosine similarity of n-grams
+//
+// Every n-gram is weighted by its inverse document frequency in fitted
+// corpus, so matching of rare n-grams count for more than matching of common
+// ones. N-grams are sets, so term frequency is always 1. IDF of n-gram
+// contained in df of n fitted strings is ln((1+n)/(1+df))+1, so unknown
+// n-grams have maximal weight.
+//
+// Fit is not safe for concurrent use with other methods, so fit the model
+// before use.
+type IDFModel struct {
+	Splitter
+	df map[string]int
+	n  int
+}
+
+// NewIDFModel is a constructor.
+func NewIDFModel(splitter Splitter) *IDFModel {
+	return &IDFModel{
+		Splitter: splitter,
+		df:       map[string]int{},
+	}
+}
+
+// Fit model by strings of corpus.
+func (model *IDFModel) Fit(ss ...string) {
+	for _, s := range ss {
+		for _, ngram := range model.Split(s) {
+			model.df[ngram]++
+		}
+	}
+
+	model.n += len(ss)
+}
+
+// Len return number of fitted strings.
+func (model *IDFModel) Len() int {
+	return model.n
+}
+
+// IDF return inverse document frequency of n-gram.
+func (model *IDFModel) IDF(ngram string) float64 {
+	return math.Log(float64(1+model.n)/float64(1+model.df[ngram])) + 1
+}
+
+// Similarity calculate TF-IDF cosine similarity of a and b.
+func (model *IDFModel) Similarity(a, b string) float64 {
+	agrams := model.Split(a)
+	bgrams := model.Split(b)
+	set := make(map[string]struct{}, len(agrams))
+	dot := 0.0
+
+	for _, agram := range agrams {
+		set[agram] = struct{}{}
+	}
+
+	sort.Strings(bgrams)
+
+	for _, bgram := range bgrams {
+		if _, ok := set[bgram]; ok {
+			idf := model.IDF(bgram)
+			dot += idf * idf
+		}
+	}
+
+	norm := model.norm(agrams) * model.norm(bgrams)
+	if norm == 0 {
+		return 0
+	}
+
+	return dot / norm
+}
+
+// Euclidean norm of IDF vector of n-grams. Weights are summed in sorted order
+// of n-grams, so norm does not depend on order of splitter.
+func (model *IDFModel) norm(ngrams []string) float64 {
+	sorted := append([]string(nil), ngrams...)
+	sort.Strings(sorted)
+
+	sum := 0.0
+
+	for _, ngram := range sorted {
+		idf := model.IDF(ngram)
+		sum += idf * idf
+	}
+
+	return math.Sqrt(sum)
+}
+
+// WithIDF rank search results by TF-IDF cosine similarity of the model
+// instead of coefficient
+//
+// Norms of strings are calculated when they are added, so model should not
+// be fitted after that.
+func WithIDF(model *IDFModel) IndexOption {
+	return func(opts *indexOptions) {
+		opts.idf = model
+	}
+}
+
+// IDFModel return model fitted by not removed strings of index.
+func (index *SplitIndex) IDFModel() *IDFModel {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	model := NewIDFModel(index.Splitter)
+
+	for ngram, id := range index.grams {
+		n := 0
+
+		index.postings[id].Each(func(i int) {
+			if !index.removed[i] {
+				n++
+			}
+		})
+
+		if n > 0 {
+			model.df[ngram] = n
+		}
+	}
+
+	for i := range index.strings {
+		if !index.removed[i] {
+			model.n++
+		}
+	}
+
+	return model
+}
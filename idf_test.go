@@ -0,0 +1,95 @@
+package muzzy_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vporoshok/muzzy"
+)
+
+func TestIDFModel(t *testing.T) {
+	corpus := []string{"kindness", "darkness", "sadness", "happiness", "madness", "kindly", "darkly"}
+	splitter := muzzy.NGramSplitter(3, true)
+	model := muzzy.NewIDFModel(splitter)
+	model.Fit(corpus...)
+
+	assert.Equal(t, len(corpus), model.Len())
+	assert.True(t, model.IDF("ss ") < model.IDF("kin"))
+	assert.True(t, model.IDF("kin") < model.IDF("zzz"))
+
+	assert.InDelta(t, 1, model.Similarity("kindness", "kindness"), 1e-9)
+	assert.Equal(t, 0.0, model.Similarity("kindness", "xyz"))
+	assert.InDelta(t, model.Similarity("kindness", "kindly"), model.Similarity("kindly", "kindness"), 1e-9)
+
+	// Common suffix count for less, rare prefix count for more.
+	assert.True(t, model.Similarity("kindness", "darkness") < splitter.Similarity("kindness", "darkness"))
+	assert.True(t, model.Similarity("kindness", "kindly") > splitter.Similarity("kindness", "kindly"))
+	assert.InDelta(t, 1, muzzy.NewIDFModel(splitter).Similarity("kindness", "kindness"), 1e-9)
+}
+
+func TestSplitIndexIDF(t *testing.T) {
+	corpus := []string{"kindness", "darkness", "sadness", "happiness", "madness", "kindly", "darkly"}
+	splitter := muzzy.NGramSplitter(3, true)
+	model := muzzy.NewIDFModel(splitter)
+	model.Fit(corpus...)
+
+	index := muzzy.NewSplitIndex(splitter, muzzy.WithIDF(model))
+	index.Add(corpus...)
+
+	fitted := index.IDFModel()
+	assert.Equal(t, model.Len(), fitted.Len())
+	assert.Equal(t, model.IDF("ss "), fitted.IDF("ss "))
+	assert.Equal(t, model.IDF("kin"), fitted.IDF("kin"))
+
+	hits := index.SearchTopK("kindnes", 10)
+	require.NotEmpty(t, hits)
+	assert.Equal(t, "kindness", hits[0].String)
+	assert.Equal(t, "kindly", hits[1].String)
+
+	for _, hit := range hits {
+		assert.InDelta(t, model.Similarity("kindnes", hit.String), hit.Score, 1e-9, hit.String)
+
+		e, ok := index.Explain("kindnes", hit.Index)
+		require.True(t, ok)
+		assert.Equal(t, hit.Score, e.Score)
+		assert.Contains(t, e.Describe(), "score = ")
+	}
+
+	assert.Equal(t, hits[:2], index.SearchThreshold("kindnes", hits[1].Score))
+	assert.Empty(t, index.SearchThreshold("kindnes", 1.1))
+
+	hit, ok := index.Find("darkly")
+	assert.True(t, ok)
+	assert.Equal(t, 6, hit.Index)
+	assert.True(t, hit.Exact)
+	assert.InDelta(t, 1, hit.Score, 1e-9)
+
+	index.Update(6, "darkling")
+	e, _ := index.Explain("darkly", 6)
+	assert.InDelta(t, model.Similarity("darkly", "darkling"), e.Score, 1e-9)
+
+	data, err := index.MarshalBinary()
+	require.NoError(t, err)
+
+	restored := muzzy.NewSplitIndex(splitter, muzzy.WithIDF(model))
+	require.NoError(t, restored.UnmarshalBinary(data))
+	assert.Equal(t, index.SearchTopK("kindnes", 10), restored.SearchTopK("kindnes", 10))
+
+	path := writeDiskIndex(t, index)
+	defer os.Remove(path)
+
+	disk, err := muzzy.OpenDiskIndex(path, nil, muzzy.WithIDF(model))
+	require.NoError(t, err)
+
+	defer disk.Close()
+
+	assert.Equal(t, index.SearchTopK("kindnes", 10), disk.SearchTopK("kindnes", 10))
+	assert.Equal(t, index.SearchThreshold("kindnes", 0.2), disk.SearchThreshold("kindnes", 0.2))
+
+	expected, _ := index.Find("darkling")
+	actual, _ := disk.Find("darkling")
+	assert.Equal(t, expected, actual)
+}
@@ -7,7 +7,16 @@ const WinklerScalingFactor = 0.1
 
 // JaroWinklerSimilarity return how close s1 and s2 increase similarity of same prefixed.
 func JaroWinklerSimilarity(s1, s2 string) float64 {
-	s := JaroSimilarity(s1, s2)
+	return winkler(JaroSimilarity(s1, s2), commonPrefix(s1, s2))
+}
+
+// Adjust Jaro similarity s by length of common prefix l.
+func winkler(s float64, l int) float64 {
+	return s + float64(l)*(1-s)*WinklerScalingFactor
+}
+
+// Length of common prefix in runes.
+func commonPrefix(s1, s2 string) int {
 	l, r1, r2 := 0, []rune(s1), []rune(s2)
 	n := len(r1)
 
@@ -18,7 +27,7 @@ func JaroWinklerSimilarity(s1, s2 string) float64 {
 	for ; l < n && r1[l] == r2[l]; l++ {
 	}
 
-	return s + float64(l)*(1-s)*WinklerScalingFactor
+	return l
 }
 
 // JaroSimilarity return how close s1 to s2
@@ -70,7 +79,11 @@ func (jc *jaroCalculator) Do() float64 {
 		return 0
 	}
 
-	t := jc.FindTranspositions()
+	return jc.Similarity(m, jc.FindTranspositions())
+}
+
+// Similarity by number of matches m and transpositions t.
+func (jc *jaroCalculator) Similarity(m, t float64) float64 {
 	n1, n2 := float64(len(jc.s1)), float64(len(jc.s2))
 
 	return (m/n1 + m/n2 + (m-t)/m) / 3
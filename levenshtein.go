@@ -1,5 +1,7 @@
 package muzzy
 
+import "context"
+
 // Distance between strings
 //
 type Distance func(s1, s2 string, bound int) int
@@ -17,19 +19,9 @@ type Distance func(s1, s2 string, bound int) int
 // number, function return -1. Use -1 as `bound` to calculate distance without
 // limitation.
 func LevenshteinDistance(s1, s2 string, bound int) int {
-	if bound == 0 {
-		if s1 == s2 {
-			return 0
-		}
-
-		return -1
-	}
-
-	b := &bounder{
-		bound: bound,
-	}
+	d, _ := distance(context.Background(), s1, s2, bound, newLevenshteinCalculator)
 
-	return b.Do(s1, s2, newLevenshteinCalculator)
+	return d
 }
 
 // DamerauDistance similar to Levenshtein except that permutation cost is 1
@@ -38,19 +30,42 @@ func LevenshteinDistance(s1, s2 string, bound int) int {
 // between "permutation" and "permtuation" is 2 (u/t, t/u), but in
 // Damerau–Levenshtein is 1.
 func DamerauDistance(s1, s2 string, bound int) int {
+	d, _ := distance(context.Background(), s1, s2, bound, newDamerauCalculator)
+
+	return d
+}
+
+// LevenshteinDistanceContext is a LevenshteinDistance, that stop calculation
+// on context cancellation and return context error.
+func LevenshteinDistanceContext(ctx context.Context, s1, s2 string, bound int) (int, error) {
+	return distance(ctx, s1, s2, bound, newLevenshteinCalculator)
+}
+
+// DamerauDistanceContext is a DamerauDistance, that stop calculation on
+// context cancellation and return context error.
+func DamerauDistanceContext(ctx context.Context, s1, s2 string, bound int) (int, error) {
+	return distance(ctx, s1, s2, bound, newDamerauCalculator)
+}
+
+func distance(ctx context.Context, s1, s2 string, bound int, calc func(r1, r2 []rune) calculator) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
 	if bound == 0 {
 		if s1 == s2 {
-			return 0
+			return 0, nil
 		}
 
-		return -1
+		return -1, nil
 	}
 
 	b := &bounder{
+		ctx:   ctx,
 		bound: bound,
 	}
 
-	return b.Do(s1, s2, newDamerauCalculator)
+	return b.Do(s1, s2, calc), b.err
 }
 
 // Calculator is an abstraction of handling prefix-distance matrix to
@@ -62,12 +77,13 @@ type calculator interface {
 }
 
 type bounder struct {
+	// Optional context checked every cellsPerCheck cells of matrix.
+	ctx    context.Context
+	err    error
 	calc   calculator
 	bound  int
 	width  int
 	height int
-	left   int
-	right  int
 }
 
 func (b *bounder) Do(s1, s2 string, calc func(r1, r2 []rune) calculator) int {
@@ -83,43 +99,71 @@ func (b *bounder) Do(s1, s2 string, calc func(r1, r2 []rune) calculator) int {
 	b.width = len(r2)
 	b.height = len(r1)
 
-	if b.bound < 0 {
+	// Distance is never great than length of the longer string, and the
+	// bound is no more than it, so band edges are not overflowed.
+	if b.bound < 0 || b.bound > len(r1) {
 		b.bound = len(r1)
 	}
 
-	b.right = len(r2)
-	if b.bound < b.right {
-		b.right = b.bound
-	}
-
 	b.calc = calc(r1, r2)
 
 	return b.Calculate()
 }
 
-// Calculate distance matrix.
+// Number of matrix cells calculated between checks of context. Rows of long
+// strings are long, so cells are counted instead of rows.
+const cellsPerCheck = 1 << 16
+
+// Calculate distance matrix
+//
+// Only cells no farther than bound from the diagonal are calculated: other
+// cells are great than bound. Cells next to the band keep values great than
+// bound, so every calculated cell is exact, if it is not great than bound.
 func (b *bounder) Calculate() int {
-	var n int
+	if b.width == 0 {
+		return b.height
+	}
 
-	for i := 0; i < b.height; i++ {
-		b.calc.Reset(b.left)
+	var n, cells int
 
-		for j := b.left; j < b.right; j++ {
-			n = b.calc.Calc(i, j)
-			if n > b.bound && j == b.left {
-				b.left++
+	for i := 0; i < b.height; i++ {
+		if b.ctx != nil && cells >= cellsPerCheck {
+			if b.err = b.ctx.Err(); b.err != nil {
+				return -1
 			}
+
+			cells = 0
 		}
 
-		if b.right < b.width && n <= b.bound {
-			b.right++
+		left, right := 0, b.width
+		if i > b.bound {
+			left = i - b.bound
 		}
 
-		if b.left >= b.right {
+		if i+b.bound+1 < right {
+			right = i + b.bound + 1
+		}
+
+		b.calc.Reset(left)
+		cells += right - left
+
+		// Distance is not less than minimal value of any row.
+		reachable := left == 0 && i < b.bound
+
+		for j := left; j < right; j++ {
+			n = b.calc.Calc(i, j)
+			reachable = reachable || n <= b.bound
+		}
+
+		if !reachable {
 			return -1
 		}
 	}
 
+	if n > b.bound {
+		return -1
+	}
+
 	return n
 }
 
@@ -177,6 +221,8 @@ func newDamerauCalculator(s1, s2 []rune) calculator {
 }
 
 func (lc *damerauCalculator) Reset(j int) {
+	// Transposition with the cell left to the band is never the best.
+	lc.buff[1] = len(lc.s1) + len(lc.s2)
 	lc.rotate(j, lc.last[j]+1)
 }
 
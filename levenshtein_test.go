@@ -1,6 +1,7 @@
 package muzzy_test
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -57,6 +58,123 @@ func TestDamerauDistance(t *testing.T) {
 	}
 }
 
+func TestBoundedDistance(t *testing.T) {
+	maxInt := int(^uint(0) >> 1)
+
+	cases := [...]struct {
+		a, b                 string
+		levenshtein, damerau int
+	}{
+		{"abc", "abd", 1, 1},
+		{"abc", "bac", 2, 1},
+		{"abc", "", 3, 3},
+		{"", "", 0, 0},
+		{"сидел", "вдали", 4, 4},
+		{"николай", "ковырял", 7, 7},
+		{"николай", "копейки", 6, 6},
+		{"happiness", "princess", 4, 4},
+	}
+
+	// Bounded distance is exact within the bound and -1 above it, whatever
+	// the bound is.
+	for _, c := range cases {
+		for _, bound := range [...]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 100, maxInt - 1, maxInt} {
+			expected := c.levenshtein
+			if expected > bound {
+				expected = -1
+			}
+
+			assert.Equal(t, expected, muzzy.LevenshteinDistance(c.a, c.b, bound), "%s/%s within %d", c.a, c.b, bound)
+
+			expected = c.damerau
+			if expected > bound {
+				expected = -1
+			}
+
+			assert.Equal(t, expected, muzzy.DamerauDistance(c.a, c.b, bound), "%s/%s within %d", c.a, c.b, bound)
+		}
+	}
+}
+
+// expiringContext is cancelled after n checks of Err.
+type expiringContext struct {
+	context.Context
+	n int
+}
+
+func (ctx *expiringContext) Err() error {
+	if ctx.n--; ctx.n < 0 {
+		return context.Canceled
+	}
+
+	return nil
+}
+
+// countingContext count checks of Err.
+type countingContext struct {
+	context.Context
+	n int
+}
+
+func (ctx *countingContext) Err() error {
+	ctx.n++
+
+	return nil
+}
+
+func TestDistanceContext(t *testing.T) {
+	s1 := strings.Repeat("happiness", 100)
+	s2 := strings.Repeat("princess", 100)
+
+	for _, distance := range [...]func(context.Context, string, string, int) (int, error){
+		muzzy.LevenshteinDistanceContext,
+		muzzy.DamerauDistanceContext,
+	} {
+		d, err := distance(context.Background(), "happiness", "princess", -1)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, d)
+
+		d, err = distance(context.Background(), "abba", "abba", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, d)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		d, err = distance(ctx, s1, s2, -1)
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, -1, d)
+
+		d, err = distance(&expiringContext{Context: context.Background(), n: 2}, s1, s2, -1)
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, -1, d)
+	}
+
+	// Context is checked by number of calculated cells, however long rows
+	// are.
+	long1, long2 := strings.Repeat("a", 4000), strings.Repeat("b", 2000)
+	ctx := &countingContext{Context: context.Background()}
+	d, err := muzzy.LevenshteinDistanceContext(ctx, long1, long2, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, 4000, d)
+	assert.True(t, ctx.n >= 4000*2000/(1<<16), ctx.n)
+
+	assert.Equal(t, muzzy.LevenshteinDistance(s1, s2, -1), mustDistance(muzzy.LevenshteinDistanceContext(
+		context.Background(), s1, s2, -1,
+	)))
+	assert.Equal(t, muzzy.DamerauDistance(s1, s2, -1), mustDistance(muzzy.DamerauDistanceContext(
+		context.Background(), s1, s2, -1,
+	)))
+}
+
+func mustDistance(d int, err error) int {
+	if err != nil {
+		panic(err)
+	}
+
+	return d
+}
+
 //nolint:funlen // long text for test
 func BenchmarkDistances(b *testing.B) {
 	join := func(chunks ...string) string { return strings.Join(chunks, " ") }
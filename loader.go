@@ -0,0 +1,166 @@
+package muzzy
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+const defaultBatchSize = 1024
+
+// Loader build SplitIndex from stream of strings
+//
+// Strings are read by batches, batches are split to n-grams by several
+// goroutines and added to index in the order of the stream, so indexes of
+// strings are the same as with sequential Add.
+type Loader struct {
+	// Workers is a number of goroutines splitting strings (GOMAXPROCS by
+	// default).
+	Workers int
+	// BatchSize is a number of strings in batch (1024 by default).
+	BatchSize int
+	// Progress is called after every added batch with total number of added
+	// strings.
+	Progress func(n int)
+}
+
+// LoadLines add every line of r to index
+//
+// Line endings are trimmed. Return number of added lines. On read error lines
+// read before the error are added.
+func (l *Loader) LoadLines(index *SplitIndex, r io.Reader) (int, error) {
+	br := bufio.NewReader(r)
+
+	return l.load(index, func() (string, error) {
+		line, err := br.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+
+		return strings.TrimSuffix(line, "\r"), err
+	})
+}
+
+// LoadCSV add given column of every CSV record to index
+//
+// Records without the column are added as empty strings. Return number of
+// added records.
+func (l *Loader) LoadCSV(index *SplitIndex, r *csv.Reader, column int) (int, error) {
+	return l.load(index, func() (string, error) {
+		record, err := r.Read()
+		if err != nil || column >= len(record) {
+			return "", err
+		}
+
+		return record[column], nil
+	})
+}
+
+func (l *Loader) load(index *SplitIndex, next func() (string, error)) (int, error) {
+	workers := l.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	var (
+		readErr  error
+		wg       sync.WaitGroup
+		read     = make(chan loaderBatch, workers)
+		prepared = make(chan loaderBatch, workers)
+	)
+
+	go func() {
+		defer close(read)
+
+		readErr = l.read(next, read)
+	}()
+
+	wg.Add(workers)
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+
+			for b := range read {
+				b.batch = index.prepare(b.batch.strings, make([]interface{}, len(b.batch.strings)))
+				prepared <- b
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(prepared)
+	}()
+
+	n, seq := 0, 0
+	pending := map[int]*batch{}
+
+	for b := range prepared {
+		pending[b.seq] = b.batch
+
+		for {
+			ready, ok := pending[seq]
+			if !ok {
+				break
+			}
+
+			delete(pending, seq)
+			index.insert(ready)
+			n += len(ready.strings)
+			seq++
+
+			if l.Progress != nil {
+				l.Progress(n)
+			}
+		}
+	}
+
+	return n, readErr
+}
+
+// loaderBatch is a batch with sequence number.
+type loaderBatch struct {
+	seq   int
+	batch *batch
+}
+
+func (l *Loader) read(next func() (string, error), read chan<- loaderBatch) error {
+	size := l.BatchSize
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+
+	for seq := 0; ; seq++ {
+		ss := make([]string, 0, size)
+
+		var err error
+
+		for len(ss) < size {
+			var s string
+
+			if s, err = next(); err != nil {
+				break
+			}
+
+			ss = append(ss, s)
+		}
+
+		if len(ss) > 0 {
+			read <- loaderBatch{seq: seq, batch: &batch{strings: ss}}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package muzzy_test
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vporoshok/muzzy"
+)
+
+func TestLoaderLines(t *testing.T) {
+	f, err := os.Open(corpusPath)
+	require.NoError(t, err)
+
+	defer f.Close()
+
+	var progress []int
+
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true))
+	loader := &muzzy.Loader{
+		Workers:   4,
+		BatchSize: 100,
+		Progress:  func(n int) { progress = append(progress, n) },
+	}
+
+	n, err := loader.LoadLines(index, f)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(readCorpus(t), "\n"), "\n")
+	expected := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true))
+	expected.Add(lines...)
+
+	assert.Equal(t, len(lines), n)
+	assert.Equal(t, n, progress[len(progress)-1])
+	assert.Len(t, progress, (n+99)/100)
+
+	for i := range lines {
+		assert.Equal(t, lines[i], index.Get(i))
+	}
+
+	query := `"Что ж баирн? у себя, что ли?"`
+	assert.Equal(t, expected.SearchTopK(query, 10), index.SearchTopK(query, 10))
+}
+
+func TestLoaderCSV(t *testing.T) {
+	data := "1,milk\r\n2,silk\n3,happiness"
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true))
+
+	n, err := new(muzzy.Loader).LoadCSV(index, csv.NewReader(strings.NewReader(data)), 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, "silk", index.Get(1))
+
+	n, err = new(muzzy.Loader).LoadCSV(index, csv.NewReader(strings.NewReader("4,\"broken")), 1)
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+}
+
+type failingReader struct {
+	data string
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+
+	return n, nil
+}
+
+func TestLoaderError(t *testing.T) {
+	errFailed := errors.New("failed")
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true))
+
+	n, err := new(muzzy.Loader).LoadLines(index, &failingReader{"milk\r\nsilk\nhappi", errFailed})
+	assert.Equal(t, errFailed, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 1, index.Search("silk"))
+
+	n, err = new(muzzy.Loader).LoadLines(index, &failingReader{"", io.EOF})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
@@ -0,0 +1,549 @@
+package muzzy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	indexMagic   = "muzzy"
+	indexVersion = 2
+	// The first version with payloads section.
+	payloadsVersion = 2
+)
+
+const (
+	customSplitter byte = iota
+	nGramSplitterKind
+)
+
+// Errors of index unmarshaling.
+var (
+	ErrInvalidFormat    = errors.New("muzzy: invalid index format")
+	ErrSplitterMismatch = errors.New("muzzy: index splitter mismatch")
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (index *SplitIndex) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+
+	if _, err := index.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (index *SplitIndex) UnmarshalBinary(data []byte) error {
+	_, err := index.ReadFrom(bytes.NewReader(data))
+
+	return err
+}
+
+// WriteTo write index to w
+//
+// Format starts with a header of magic string and version, followed by the
+// splitter configuration, strings, posting lists and payloads encoded with
+// encoding/gob. Configuration of splitters created by NGramSplitter is
+// written, so index may be read without splitter. Custom splitters are not
+// written, and index should be read with the same splitter. Index options are
+// not written too.
+func (index *SplitIndex) WriteTo(w io.Writer) (int64, error) {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	bw := &binaryWriter{w: bufio.NewWriter(w)}
+	bw.String(indexMagic)
+	bw.Uvarint(indexVersion)
+
+	if splitter, ok := index.Splitter.(nGramSplitter); ok {
+		bw.Byte(nGramSplitterKind)
+		bw.Uvarint(uint64(splitter.n))
+		bw.Bool(splitter.withPadding)
+	} else {
+		bw.Byte(customSplitter)
+	}
+
+	bw.Uvarint(uint64(len(index.strings)))
+
+	for i, s := range index.strings {
+		bw.Bool(index.removed[i])
+		bw.String(s)
+		bw.Uvarint(uint64(index.sizes[i]))
+	}
+
+	ngrams := index.sortedGrams()
+	bw.Uvarint(uint64(len(ngrams)))
+
+	for _, ngram := range ngrams {
+		postings := &index.postings[index.grams[ngram]]
+		bw.String(ngram)
+		bw.Uvarint(uint64(postings.Len()))
+
+		last := 0
+		postings.Each(func(i int) {
+			bw.Uvarint(uint64(i - last))
+			last = i
+		})
+	}
+
+	if err := index.writePayloads(bw); err != nil {
+		return bw.n, err
+	}
+
+	return bw.Flush()
+}
+
+// Payloads are written with encoding/gob, so custom payload types should be
+// registered with gob.Register.
+func (index *SplitIndex) writePayloads(bw *binaryWriter) error {
+	data, err := index.encodePayloads()
+	if err != nil {
+		return err
+	}
+
+	bw.Bool(data != nil)
+
+	if data != nil {
+		bw.String(string(data))
+	}
+
+	return nil
+}
+
+// Encode payloads with encoding/gob. Return nil if index has no payloads.
+func (index *SplitIndex) encodePayloads() ([]byte, error) {
+	empty := true
+
+	for _, payload := range index.payloads {
+		if payload != nil {
+			empty = false
+			break
+		}
+	}
+
+	if empty {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+
+	if err := gob.NewEncoder(&buf).Encode(index.payloads); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ReadFrom replace index content with index read from r
+//
+// If index has no splitter, splitter is restored from configuration.
+// Otherwise its configuration should be the same as the written one. If r is
+// not an io.ByteReader it is buffered, so r may be read beyond the index end.
+// Indexes written in previous versions of format are read too.
+func (index *SplitIndex) ReadFrom(r io.Reader) (int64, error) {
+	br := newBinaryReader(r)
+	restored := &SplitIndex{grams: map[string]uint32{}}
+
+	splitter, version, err := readHeader(br)
+	if err == nil {
+		err = restored.readStrings(br)
+	}
+
+	if err == nil {
+		err = restored.readPostings(br)
+	}
+
+	if err == nil {
+		err = restored.readPayloads(br, version)
+	}
+
+	if err != nil {
+		return br.n, err
+	}
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	if index.Splitter, err = restoreSplitter(index.Splitter, splitter); err != nil {
+		return br.n, err
+	}
+
+	if index.coefficient == nil {
+		index.coefficient = OtsukaOchiai
+	}
+
+	index.grams = restored.grams
+	index.postings = restored.postings
+	index.frequencies = restored.frequencies
+	index.exact = map[string][]int{}
+	index.strings = restored.strings
+	index.sizes = restored.sizes
+	index.removed = restored.removed
+	index.payloads = restored.payloads
+
+	index.norms, index.repeated = nil, nil
+	index.live, index.sizeSum = 0, 0
+
+	for i, s := range index.strings {
+		if !index.removed[i] {
+			normalized := index.normalizeString(s)
+			index.exact[normalized] = append(index.exact[normalized], i)
+			index.live++
+			index.sizeSum += index.sizes[i]
+		}
+
+		if index.idf != nil {
+			index.norms = append(index.norms, index.idf.norm(index.Split(s)))
+		}
+
+		if index.bm25 != nil {
+			index.repeated = append(index.repeated, repeatedGrams(index.Splitter, s))
+		}
+	}
+
+	return br.n, nil
+}
+
+func (index *SplitIndex) sortedGrams() []string {
+	ngrams := make([]string, 0, len(index.grams))
+	for ngram := range index.grams {
+		ngrams = append(ngrams, ngram)
+	}
+
+	sort.Strings(ngrams)
+
+	return ngrams
+}
+
+// Choose splitter of restored index. Stored splitter is nil for custom
+// splitters.
+func restoreSplitter(current, stored Splitter) (Splitter, error) {
+	if current == nil {
+		if stored == nil {
+			return nil, ErrSplitterMismatch
+		}
+
+		return stored, nil
+	}
+
+	if stored == nil {
+		return current, nil
+	}
+
+	c, ok := current.(nGramSplitter)
+	s := stored.(nGramSplitter)
+
+	if !ok || c.n != s.n || c.withPadding != s.withPadding {
+		return current, ErrSplitterMismatch
+	}
+
+	return current, nil
+}
+
+func readHeader(br *binaryReader) (Splitter, uint64, error) {
+	magic := br.String()
+	version := br.Uvarint()
+
+	if br.err != nil || magic != indexMagic {
+		return nil, version, ErrInvalidFormat
+	}
+
+	if version == 0 || version > indexVersion {
+		return nil, version, fmt.Errorf("muzzy: unsupported index version %d", version)
+	}
+
+	switch br.Byte() {
+	case customSplitter:
+		return nil, version, br.err
+	case nGramSplitterKind:
+		n := int(br.Uvarint())
+		withPadding := br.Bool()
+
+		if br.err != nil || n == 0 {
+			return nil, version, ErrInvalidFormat
+		}
+
+		return NGramSplitter(n, withPadding), version, nil
+	default:
+		return nil, version, ErrInvalidFormat
+	}
+}
+
+func (index *SplitIndex) readStrings(br *binaryReader) error {
+	n := br.Len()
+	index.strings = make([]string, 0, min(n, maxPrealloc))
+	index.sizes = make([]int, 0, min(n, maxPrealloc))
+	index.removed = make([]bool, 0, min(n, maxPrealloc))
+
+	for i := 0; i < n && br.err == nil; i++ {
+		index.removed = append(index.removed, br.Bool())
+		index.strings = append(index.strings, br.String())
+		index.sizes = append(index.sizes, br.Len())
+	}
+
+	return br.err
+}
+
+func (index *SplitIndex) readPostings(br *binaryReader) error {
+	n := br.Len()
+
+	for i := 0; i < n && br.err == nil; i++ {
+		ngram := br.String()
+		m := br.Len()
+		if _, ok := index.grams[ngram]; ok {
+			return ErrInvalidFormat
+		}
+
+		var postings postingList
+
+		last, frequency := 0, 0
+
+		for j := 0; j < m && br.err == nil; j++ {
+			// Delta is checked before addition, so crafted deltas do not
+			// overflow last.
+			delta := br.Len()
+			if delta >= len(index.strings)-last || (j > 0 && delta == 0) {
+				return ErrInvalidFormat
+			}
+
+			last += delta
+
+			postings.Append(last)
+
+			if !index.removed[last] {
+				frequency++
+			}
+		}
+
+		index.grams[ngram] = uint32(len(index.postings))
+		index.postings = append(index.postings, postings)
+		index.frequencies = append(index.frequencies, frequency)
+	}
+
+	return br.err
+}
+
+func (index *SplitIndex) readPayloads(br *binaryReader, version uint64) error {
+	if version < payloadsVersion || !br.Bool() {
+		index.payloads = make([]interface{}, len(index.strings))
+
+		return br.err
+	}
+
+	data := br.String()
+	if br.err != nil {
+		return br.err
+	}
+
+	payloads, err := decodePayloads(strings.NewReader(data), len(index.strings))
+	index.payloads = payloads
+
+	return err
+}
+
+// Decode payloads of n strings encoded by encodePayloads.
+func decodePayloads(r io.Reader, n int) ([]interface{}, error) {
+	var payloads []interface{}
+
+	if err := gob.NewDecoder(r).Decode(&payloads); err != nil {
+		return nil, err
+	}
+
+	if len(payloads) != n {
+		return nil, ErrInvalidFormat
+	}
+
+	return payloads, nil
+}
+
+// binaryWriter write variable length encoded values and remember the first
+// error.
+type binaryWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (bw *binaryWriter) Write(p []byte) {
+	if bw.err != nil {
+		return
+	}
+
+	n, err := bw.w.Write(p)
+	bw.n += int64(n)
+	bw.err = err
+}
+
+func (bw *binaryWriter) Uvarint(x uint64) {
+	n := binary.PutUvarint(bw.buf[:], x)
+	bw.Write(bw.buf[:n])
+}
+
+func (bw *binaryWriter) Uint64(x uint64) {
+	binary.LittleEndian.PutUint64(bw.buf[:], x)
+	bw.Write(bw.buf[:8])
+}
+
+func (bw *binaryWriter) Uint32(x uint32) {
+	binary.LittleEndian.PutUint32(bw.buf[:], x)
+	bw.Write(bw.buf[:4])
+}
+
+func (bw *binaryWriter) Byte(b byte) {
+	bw.buf[0] = b
+	bw.Write(bw.buf[:1])
+}
+
+func (bw *binaryWriter) Bool(b bool) {
+	if b {
+		bw.Byte(1)
+	} else {
+		bw.Byte(0)
+	}
+}
+
+func (bw *binaryWriter) String(s string) {
+	bw.Uvarint(uint64(len(s)))
+	bw.Raw(s)
+}
+
+// Raw write string without length.
+func (bw *binaryWriter) Raw(s string) {
+	if bw.err == nil {
+		n, err := bw.w.WriteString(s)
+		bw.n += int64(n)
+		bw.err = err
+	}
+}
+
+func (bw *binaryWriter) Flush() (int64, error) {
+	if bw.err == nil {
+		bw.err = bw.w.Flush()
+	}
+
+	return bw.n, bw.err
+}
+
+// binaryReader read variable length encoded values and remember the first
+// error. Unexpected end of data is reported as ErrInvalidFormat.
+type binaryReader struct {
+	r   byteReader
+	n   int64
+	err error
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+func newBinaryReader(r io.Reader) *binaryReader {
+	br, ok := r.(byteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	return &binaryReader{r: br}
+}
+
+func (br *binaryReader) ReadByte() (byte, error) {
+	b, err := br.r.ReadByte()
+	if err == nil {
+		br.n++
+	}
+
+	return b, err
+}
+
+func (br *binaryReader) Read(p []byte) (int, error) {
+	n, err := br.r.Read(p)
+	br.n += int64(n)
+
+	return n, err
+}
+
+func (br *binaryReader) fail(err error) {
+	if br.err != nil {
+		return
+	}
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = ErrInvalidFormat
+	}
+
+	br.err = err
+}
+
+func (br *binaryReader) Uvarint() uint64 {
+	if br.err != nil {
+		return 0
+	}
+
+	x, err := binary.ReadUvarint(br)
+	if err != nil {
+		br.fail(err)
+	}
+
+	return x
+}
+
+// Len read non-negative int value.
+func (br *binaryReader) Len() int {
+	x := br.Uvarint()
+	if x > uint64(maxInt) {
+		br.fail(ErrInvalidFormat)
+		return 0
+	}
+
+	return int(x)
+}
+
+func (br *binaryReader) Byte() byte {
+	if br.err != nil {
+		return 0
+	}
+
+	b, err := br.ReadByte()
+	if err != nil {
+		br.fail(err)
+	}
+
+	return b
+}
+
+func (br *binaryReader) Bool() bool {
+	return br.Byte() != 0
+}
+
+// String is copied by chunks, so broken length does not lead to huge
+// allocation.
+func (br *binaryReader) String() string {
+	n := br.Len()
+	if br.err != nil || n == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	if _, err := io.CopyN(&sb, br, int64(n)); err != nil {
+		br.fail(err)
+	}
+
+	return sb.String()
+}
+
+const maxInt = int(^uint(0) >> 1)
+
+// Lengths are read from untrusted data, so slices are not preallocated
+// greater than maxPrealloc.
+const maxPrealloc = 1 << 16
@@ -0,0 +1,149 @@
+package muzzy_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vporoshok/muzzy"
+)
+
+func TestSplitIndexMarshaling(t *testing.T) {
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true))
+	index.Add(readCorpusLines(t)...)
+	index.Remove(0)
+
+	data, err := index.MarshalBinary()
+	require.NoError(t, err)
+
+	restored := new(muzzy.SplitIndex)
+	require.NoError(t, restored.UnmarshalBinary(data))
+
+	queries := [...]string{
+		`"Что ж баирн? у себя, что ли?"`,
+		"Николай Васильевич Гоголь",
+		"Чичиков",
+		"not found",
+	}
+
+	for _, query := range queries {
+		assert.Equal(t, index.Search(query), restored.Search(query), query)
+		assert.Equal(t, index.SearchTopK(query, 10), restored.SearchTopK(query, 10), query)
+	}
+
+	assert.Equal(t, "", restored.Get(0))
+	assert.False(t, restored.Remove(0))
+
+	var buf bytes.Buffer
+
+	n, err := restored.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.Equal(t, data, buf.Bytes())
+
+	n, err = muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true)).ReadFrom(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+}
+
+func TestSplitIndexPayloadMarshaling(t *testing.T) {
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true))
+	index.Add("milk")
+	index.AddWithPayload("silk", 42)
+	index.AddWithPayload("happiness", "key")
+
+	data, err := index.MarshalBinary()
+	require.NoError(t, err)
+
+	restored := new(muzzy.SplitIndex)
+	require.NoError(t, restored.UnmarshalBinary(data))
+	assert.Nil(t, restored.Payload(0))
+	assert.Equal(t, 42, restored.Payload(1))
+	assert.Equal(t, "key", restored.Payload(2))
+
+	type unregistered struct{ ID int }
+
+	index.AddWithPayload("princess", unregistered{1})
+
+	_, err = index.MarshalBinary()
+	assert.Error(t, err)
+}
+
+func TestSplitIndexFormatVersions(t *testing.T) {
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true))
+	index.Add("milk", "silk", "happiness")
+
+	data, err := index.MarshalBinary()
+	require.NoError(t, err)
+
+	// Version follows length-prefixed magic string. The first version has no
+	// payloads section, which is a single false flag without payloads.
+	version := 1 + len("muzzy")
+	v1 := append([]byte(nil), data[:len(data)-1]...)
+	v1[version] = 1
+
+	restored := new(muzzy.SplitIndex)
+	require.NoError(t, restored.UnmarshalBinary(v1))
+	assert.Equal(t, index.SearchTopK("milk", 3), restored.SearchTopK("milk", 3))
+	assert.Nil(t, restored.Payload(1))
+
+	for _, v := range [...]byte{0, 3} {
+		unsupported := append([]byte(nil), data...)
+		unsupported[version] = v
+
+		err = new(muzzy.SplitIndex).UnmarshalBinary(unsupported)
+		assert.EqualError(t, err, fmt.Sprintf("muzzy: unsupported index version %d", v))
+	}
+}
+
+func TestSplitIndexUnmarshalingErrors(t *testing.T) {
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(2, false))
+	index.Add("milk", "silk")
+
+	data, err := index.MarshalBinary()
+	require.NoError(t, err)
+
+	err = muzzy.NewSplitIndex(muzzy.NGramSplitter(3, false)).UnmarshalBinary(data)
+	assert.Equal(t, muzzy.ErrSplitterMismatch, err)
+
+	for i := 0; i < len(data); i++ {
+		err = new(muzzy.SplitIndex).UnmarshalBinary(data[:i])
+		assert.Equal(t, muzzy.ErrInvalidFormat, err, "truncated to %d", i)
+	}
+
+	err = new(muzzy.SplitIndex).UnmarshalBinary([]byte("not an index"))
+	assert.Equal(t, muzzy.ErrInvalidFormat, err)
+
+	// Posting list of "a" in index of "a" and "b a" is 0, 1. Deltas 1 and
+	// max int overflow the string index.
+	words := muzzy.NewSplitIndex(muzzy.SplitterFunc(strings.Fields))
+	words.Add("a", "b a")
+
+	data, err = words.MarshalBinary()
+	require.NoError(t, err)
+
+	postings := []byte{1, 'a', 2, 0, 1}
+	require.True(t, bytes.Contains(data, postings))
+
+	overflow := append([]byte{1, 'a', 2, 1}, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f)
+	data = bytes.Replace(data, postings, overflow, 1)
+	err = muzzy.NewSplitIndex(muzzy.SplitterFunc(strings.Fields)).UnmarshalBinary(data)
+	assert.Equal(t, muzzy.ErrInvalidFormat, err)
+
+	custom := muzzy.NewSplitIndex(muzzy.SplitterFunc(strings.Fields))
+	custom.Add("red milk", "white silk")
+
+	data, err = custom.MarshalBinary()
+	require.NoError(t, err)
+
+	err = new(muzzy.SplitIndex).UnmarshalBinary(data)
+	assert.Equal(t, muzzy.ErrSplitterMismatch, err)
+
+	restored := muzzy.NewSplitIndex(muzzy.SplitterFunc(strings.Fields))
+	require.NoError(t, restored.UnmarshalBinary(data))
+	assert.Equal(t, 1, restored.Search("silk"))
+}
@@ -0,0 +1,262 @@
+package muzzy
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// maxScoreList is a posting list of n-gram of the query in MaxScore search.
+type maxScoreList struct {
+	ngram string
+	// Weight of the n-gram in the query and upper bound of its weight in any
+	// string.
+	weight, bound float64
+	// Number of postings.
+	length int
+}
+
+// Relative error of score bounds, so rounding never prune string with the
+// same score as the worst of top.
+const maxScoreEpsilon = 1e-9
+
+// Minimal ratio of number of read postings to number of found strings
+// between checks of top k.
+const checkFactor = 4
+
+// Dense accumulators of weights reused between queries without own
+// accumulator. Buffers are zero between uses.
+var accumulators = sync.Pool{
+	New: func() interface{} { return new([]float64) },
+}
+
+// MaxScore search of top k hits
+//
+// Posting lists are read from heavy and short ones to light and long ones.
+// Before every list the k-th best partial score of found strings is checked:
+// partial score is not greater than the final one, so if the rest lists
+// together can not give such score, strings first found in them can not get
+// into top k. From that moment rest lists are only probed for found strings,
+// which may still get into top k with bounds of the rest lists, and blocks of
+// postings without them are skipped by skip pointers. Weights are summed in
+// order of the query, so scores and hits are the same as with counting of all
+// postings, but accumulators are dense and blocks of long lists without
+// candidates are not decoded.
+//
+// Return false if scores has no upper bounds.
+func (q *query) maxScoreTopK(k int) ([]Hit, bool) {
+	q.prepare()
+
+	if q.bm25 != nil && (q.bm25.k1 < 0 || q.bm25.b < 0 || q.bm25.b > 1) {
+		return nil, false
+	}
+
+	lists, rest := q.maxScoreLists()
+	bound := q.scoreBound()
+
+	buf := q.accumulator
+	if buf == nil {
+		buf = accumulators.Get().(*[]float64)
+		defer accumulators.Put(buf)
+	}
+
+	if n := q.source.total(); len(*buf) < n {
+		*buf = make([]float64, n)
+	}
+
+	acc := *buf
+	found := []int{}
+	scores := make([]float64, 0, min(k, q.source.total()))
+	open := true
+	// Number of read postings and its value at the last check.
+	read, checked := 0, 0
+
+	// Found strings, that may get into top k, in ascending order after
+	// search is closed.
+	var candidates []int
+
+	for _, list := range lists {
+		if !q.alive() {
+			break
+		}
+
+		// Check costs more than reading of found number of postings, so it
+		// is done after reading of several times such number of postings.
+		if open && len(found) >= k && read-checked >= checkFactor*len(found) {
+			kth := q.kthScore(acc, found, k, scores)
+			checked = read
+
+			if bound(rest)*(1+maxScoreEpsilon) < kth {
+				open = false
+				candidates = q.pruneCandidates(acc, append(candidates, found...), rest, kth)
+				sort.Ints(candidates)
+			}
+		}
+
+		weight := list.weight
+		rest -= list.bound
+
+		// Weights are positive, so found strings have non-zero
+		// accumulators.
+		add := func(i, tf int) bool {
+			if acc[i] == 0 {
+				found = append(found, i)
+			}
+
+			if q.bm25 != nil {
+				acc[i] += q.postingWeight(weight, i, tf)
+			} else {
+				acc[i] += weight
+			}
+
+			read++
+
+			return read%postingsPerCheck != 0 || q.alive()
+		}
+
+		if open {
+			q.source.eachPosting(list.ngram, add)
+		} else {
+			q.source.eachPostingOf(list.ngram, candidates, add)
+		}
+	}
+
+	top := make(hitHeap, 0, min(k, len(found)))
+
+	for _, i := range found {
+		top.Offer(Hit{Index: i, Score: q.score(i, acc[i])}, k)
+		acc[i] = 0
+	}
+
+	return q.fill(top), true
+}
+
+// Lists of the query n-grams in order of reading with total bound. Weighted
+// n-grams are already sorted by weight, others are sorted by length.
+func (q *query) maxScoreLists() ([]maxScoreList, float64) {
+	lists := make([]maxScoreList, len(q.ngrams))
+	bound := 0.0
+
+	for j, ngram := range q.ngrams {
+		list := &lists[j]
+		list.ngram = ngram
+		list.weight = q.weight(ngram)
+		list.bound = list.weight
+		list.length = q.source.frequency(ngram)
+
+		if q.bm25 != nil {
+			list.bound *= q.bm25.k1 + 1
+		}
+
+		bound += list.bound
+	}
+
+	if q.idf == nil && q.bm25 == nil {
+		sort.Sort(byLength(lists))
+	}
+
+	return lists, bound
+}
+
+// Keep candidates, that may reach score kth, if the rest lists add their
+// bounds to them. Candidates out of top k are not needed to be probed.
+func (q *query) pruneCandidates(acc []float64, candidates []int, rest, kth float64) []int {
+	kept := candidates[:0]
+
+	for _, i := range candidates {
+		if q.score(i, acc[i]+rest)*(1+maxScoreEpsilon) >= kth {
+			kept = append(kept, i)
+		}
+	}
+
+	return kept
+}
+
+// The k-th best partial score of found strings. Scores are kept in a min-heap
+// of k values in scores buffer.
+func (q *query) kthScore(acc []float64, found []int, k int, scores []float64) float64 {
+	scores = scores[:0]
+
+	for _, i := range found {
+		score := q.score(i, acc[i])
+
+		switch {
+		case len(scores) < k:
+			scores = append(scores, score)
+
+			for j := len(scores) - 1; j > 0 && scores[(j-1)/2] > scores[j]; j = (j - 1) / 2 {
+				scores[j], scores[(j-1)/2] = scores[(j-1)/2], scores[j]
+			}
+		case score > scores[0]:
+			scores[0] = score
+			siftDown(scores)
+		}
+	}
+
+	return scores[0]
+}
+
+// Restore min-heap with changed root.
+func siftDown(h []float64) {
+	for j := 0; ; {
+		least, l, r := j, 2*j+1, 2*j+2
+		if l < len(h) && h[l] < h[least] {
+			least = l
+		}
+
+		if r < len(h) && h[r] < h[least] {
+			least = r
+		}
+
+		if least == j {
+			return
+		}
+
+		h[j], h[least] = h[least], h[j]
+		j = least
+	}
+}
+
+// Upper bound of score of any string with total weight of common n-grams up
+// to count. String with common n-grams weighted by count has IDF norm at
+// least sqrt(count). Coefficient is assumed to be maximal, when string
+// contains no other n-grams, as in minCommon.
+func (q *query) scoreBound() func(count float64) float64 {
+	switch {
+	case q.bm25 != nil:
+		return func(count float64) float64 { return count }
+	case q.idf != nil:
+		return func(count float64) float64 {
+			if q.queryNorm == 0 {
+				return 0
+			}
+
+			return math.Sqrt(count) / q.queryNorm
+		}
+	}
+
+	n := len(q.ngrams)
+	bounds := make([]float64, n+1)
+
+	for common := 1; common <= n; common++ {
+		bounds[common] = math.Max(bounds[common-1], q.coefficient(common, n, common))
+	}
+
+	return func(count float64) float64 {
+		return bounds[min(int(count+0.5), n)]
+	}
+}
+
+// byLength sort lists by length and n-gram.
+type byLength []maxScoreList
+
+func (l byLength) Len() int      { return len(l) }
+func (l byLength) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+
+func (l byLength) Less(i, j int) bool {
+	if l[i].length != l[j].length {
+		return l[i].length < l[j].length
+	}
+
+	return l[i].ngram < l[j].ngram
+}
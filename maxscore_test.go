@@ -0,0 +1,120 @@
+package muzzy_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vporoshok/muzzy"
+)
+
+func TestSplitIndexMaxScore(t *testing.T) {
+	lines := readCorpusLines(t)
+	splitter := muzzy.NGramSplitter(3, true)
+	model := muzzy.NewIDFModel(splitter)
+	model.Fit(lines...)
+
+	options := map[string][]muzzy.IndexOption{
+		"OtsukaOchiai": nil,
+		"Jaccard":      {muzzy.WithCoefficient(muzzy.Jaccard)},
+		"Dice":         {muzzy.WithCoefficient(muzzy.Dice)},
+		"IDF":          {muzzy.WithIDF(model)},
+		"BM25":         {muzzy.WithBM25(muzzy.DefaultBM25K1, muzzy.DefaultBM25B)},
+		"StopGrams":    {muzzy.WithStopGrams(0.05)},
+	}
+
+	queries := []string{"Чичиков", "Николай Васильевич Гоголь", "not found", "и", ""}
+	for i := 0; i < len(lines); i += 97 {
+		queries = append(queries, lines[i])
+	}
+
+	for name, opts := range options {
+		index := muzzy.NewSplitIndex(splitter, opts...)
+		index.Add(lines...)
+
+		for i := 0; i < len(lines); i += 13 {
+			index.Remove(i)
+		}
+
+		path := writeDiskIndex(t, index)
+		defer os.Remove(path)
+
+		disk, err := muzzy.OpenDiskIndex(path, nil, opts...)
+		require.NoError(t, err)
+
+		defer disk.Close()
+
+		for _, query := range queries {
+			all := index.SearchThreshold(query, 0)
+
+			for _, k := range [...]int{1, 3, 10, 100} {
+				expected := all
+				if len(expected) > k {
+					expected = expected[:k]
+				}
+
+				actual := index.SearchTopK(query, k)
+				if len(expected) == 0 {
+					assert.Empty(t, actual, "%s %q top %d", name, query, k)
+				} else {
+					assert.Equal(t, expected, actual, "%s %q top %d", name, query, k)
+				}
+
+				actual = disk.SearchTopK(query, k)
+				if len(expected) == 0 {
+					assert.Empty(t, actual, "%s %q disk top %d", name, query, k)
+				} else {
+					assert.Equal(t, expected, actual, "%s %q disk top %d", name, query, k)
+				}
+			}
+		}
+	}
+}
+
+func TestSearchTopKHugeK(t *testing.T) {
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true))
+	index.Add("milk", "silk", "happiness", "princess", "mile")
+	index.Remove(1)
+
+	path := writeDiskIndex(t, index)
+	defer os.Remove(path)
+
+	disk, err := muzzy.OpenDiskIndex(path, nil)
+	require.NoError(t, err)
+
+	defer disk.Close()
+
+	expected := index.SearchThreshold("milk", 0)
+	require.NotEmpty(t, expected)
+
+	for _, k := range [...]int{1 << 40, int(^uint(0) >> 1)} {
+		assert.Equal(t, expected, index.SearchTopK("milk", k), k)
+		assert.Equal(t, expected, disk.SearchTopK("milk", k), k)
+	}
+}
+
+func BenchmarkSplitIndexTopK(b *testing.B) {
+	lines := readCorpusLines(b)
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true))
+	index.Add(lines...)
+
+	b.Run("TopK", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			index.SearchTopK(lines[i%len(lines)], 10)
+		}
+	})
+
+	b.Run("Exhaustive", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			index.SearchTopKExhaustive(lines[i%len(lines)], 10)
+		}
+	})
+
+	b.Run("Threshold", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			index.SearchThreshold(lines[i%len(lines)], 0.5)
+		}
+	})
+}
@@ -0,0 +1,247 @@
+package muzzy
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+	"sync"
+)
+
+// MinHash make signatures of strings to estimate Jaccard coefficient of their
+// n-gram sets
+//
+// Every value of signature is the minimum of one hash function over n-grams
+// of the string, so probability of equal values in two signatures is Jaccard
+// coefficient of n-gram sets. Hash functions are derived from seed, so
+// signatures with the same seed and size are comparable between runs.
+type MinHash struct {
+	Splitter
+	seeds []uint64
+}
+
+// Value of signature of string without n-grams.
+const emptyMinHash = math.MaxUint64
+
+// NewMinHash is a constructor of signatures of given size.
+func NewMinHash(splitter Splitter, size int, seed int64) *MinHash {
+	m := &MinHash{Splitter: splitter, seeds: make([]uint64, size)}
+	state := uint64(seed)
+
+	for i := range m.seeds {
+		state += 0x9e3779b97f4a7c15
+		m.seeds[i] = mix64(state)
+	}
+
+	return m
+}
+
+// Size return number of values in signature.
+func (m *MinHash) Size() int {
+	return len(m.seeds)
+}
+
+// Signature of the string.
+func (m *MinHash) Signature(s string) []uint64 {
+	signature := make([]uint64, len(m.seeds))
+	for i := range signature {
+		signature[i] = emptyMinHash
+	}
+
+	h := fnv.New64a()
+
+	for _, ngram := range m.Split(s) {
+		h.Reset()
+		_, _ = h.Write([]byte(ngram))
+		x := h.Sum64()
+
+		for i, seed := range m.seeds {
+			if v := mix64(x ^ seed); v < signature[i] {
+				signature[i] = v
+			}
+		}
+	}
+
+	return signature
+}
+
+// EstimateJaccard return share of equal values of two signatures
+//
+// Strings without n-grams have zero coefficient as in Jaccard.
+func EstimateJaccard(signature1, signature2 []uint64) float64 {
+	n := min(len(signature1), len(signature2))
+	if n == 0 {
+		return 0
+	}
+
+	equal := 0
+
+	for i := 0; i < n; i++ {
+		if signature1[i] == signature2[i] && signature1[i] != emptyMinHash {
+			equal++
+		}
+	}
+
+	return float64(equal) / float64(n)
+}
+
+// Finalizer of SplitMix64 generator.
+func mix64(x uint64) uint64 {
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+
+	return x ^ (x >> 31)
+}
+
+// LSHIndex index to find near-duplicate strings by MinHash signatures
+//
+// Signature is divided to bands of rows, and strings with equal band are
+// candidates. Probability of strings with Jaccard coefficient j to be
+// candidates is 1-(1-j^rows)^bands, it is an S-curve with the middle near
+// LSHThreshold(bands, rows). LSHIndex is safe for concurrent use.
+type LSHIndex struct {
+	mu         sync.RWMutex
+	minHash    *MinHash
+	bands      int
+	rows       int
+	buckets    []map[uint64][]int
+	signatures [][]uint64
+	strings    []string
+}
+
+// CandidatePair is a pair of near-duplicate strings.
+type CandidatePair struct {
+	// Indexes of strings, A is less than B.
+	A, B int
+	// Jaccard is the estimated coefficient.
+	Jaccard float64
+}
+
+// LSHThreshold return approximate Jaccard coefficient, at which strings
+// become candidates with given bands and rows.
+func LSHThreshold(bands, rows int) float64 {
+	return math.Pow(1/float64(bands), 1/float64(rows))
+}
+
+// NewLSHIndex is a constructor
+//
+// Signatures of bands*rows values are made with seed.
+func NewLSHIndex(splitter Splitter, bands, rows int, seed int64) *LSHIndex {
+	if bands < 1 {
+		bands = 1
+	}
+
+	if rows < 1 {
+		rows = 1
+	}
+
+	index := &LSHIndex{
+		minHash: NewMinHash(splitter, bands*rows, seed),
+		bands:   bands,
+		rows:    rows,
+		buckets: make([]map[uint64][]int, bands),
+	}
+
+	for band := range index.buckets {
+		index.buckets[band] = map[uint64][]int{}
+	}
+
+	return index
+}
+
+// Add strings to index.
+func (index *LSHIndex) Add(ss ...string) {
+	signatures := make([][]uint64, len(ss))
+	for i, s := range ss {
+		signatures[i] = index.minHash.Signature(s)
+	}
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	for i, signature := range signatures {
+		id := len(index.strings)
+		index.strings = append(index.strings, ss[i])
+		index.signatures = append(index.signatures, signature)
+
+		for band, buckets := range index.buckets {
+			key := index.bandKey(signature, band)
+			buckets[key] = append(buckets[key], id)
+		}
+	}
+}
+
+// Len return number of strings in index.
+func (index *LSHIndex) Len() int {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	return len(index.strings)
+}
+
+// Get string by index.
+func (index *LSHIndex) Get(i int) string {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	if i < 0 || i >= len(index.strings) {
+		return ""
+	}
+
+	return index.strings[i]
+}
+
+// Pairs return candidate pairs with estimated Jaccard coefficient great or
+// equal to threshold
+//
+// Pairs are sorted by coefficient in descending order, then by indexes.
+func (index *LSHIndex) Pairs(threshold float64) []CandidatePair {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	seen := map[[2]int]struct{}{}
+
+	var pairs []CandidatePair
+
+	for _, buckets := range index.buckets {
+		for _, ids := range buckets {
+			for x, a := range ids {
+				for _, b := range ids[x+1:] {
+					if _, ok := seen[[2]int{a, b}]; ok {
+						continue
+					}
+
+					seen[[2]int{a, b}] = struct{}{}
+
+					if j := EstimateJaccard(index.signatures[a], index.signatures[b]); j >= threshold {
+						pairs = append(pairs, CandidatePair{A: a, B: b, Jaccard: j})
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		a, b := pairs[i], pairs[j]
+
+		switch {
+		case a.Jaccard != b.Jaccard:
+			return a.Jaccard > b.Jaccard
+		case a.A != b.A:
+			return a.A < b.A
+		}
+
+		return a.B < b.B
+	})
+
+	return pairs
+}
+
+// Hash of rows of the band.
+func (index *LSHIndex) bandKey(signature []uint64, band int) uint64 {
+	key := uint64(band)
+	for _, v := range signature[band*index.rows : (band+1)*index.rows] {
+		key = mix64(key ^ v)
+	}
+
+	return key
+}
@@ -0,0 +1,155 @@
+package muzzy_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vporoshok/muzzy"
+)
+
+func TestMinHash(t *testing.T) {
+	splitter := muzzy.NGramSplitter(3, true)
+	m := muzzy.NewMinHash(splitter, 256, 42)
+	require.Equal(t, 256, m.Size())
+
+	lines := corpusLines(t, 100)
+	signatures := make([][]uint64, len(lines))
+
+	for i, line := range lines {
+		signatures[i] = m.Signature(line)
+	}
+
+	assert.Equal(t, signatures[0], muzzy.NewMinHash(splitter, 256, 42).Signature(lines[0]))
+	assert.NotEqual(t, signatures[0], muzzy.NewMinHash(splitter, 256, 43).Signature(lines[0]))
+
+	// Standard deviation of estimation is not great than 1/(2*sqrt(256)), so
+	// estimation is within 4 deviations.
+	for i := 1; i < len(lines); i++ {
+		for _, s := range [...]string{lines[i-1], lines[i][1:], strings.ToUpper(lines[i])} {
+			grams1, grams2 := splitter.Split(lines[i]), splitter.Split(s)
+			common := 0
+
+			set := map[string]bool{}
+			for _, gram := range grams1 {
+				set[gram] = true
+			}
+
+			for _, gram := range grams2 {
+				if set[gram] {
+					common++
+				}
+			}
+
+			expected := muzzy.Jaccard(common, len(grams1), len(grams2))
+			actual := muzzy.EstimateJaccard(signatures[i], m.Signature(s))
+			assert.InDelta(t, expected, actual, 4/(2*math.Sqrt(256)), "%q / %q", lines[i], s)
+		}
+	}
+
+	assert.Equal(t, 1.0, muzzy.EstimateJaccard(signatures[0], signatures[0]))
+
+	words := muzzy.NewMinHash(muzzy.SplitterFunc(strings.Fields), 16, 42)
+	assert.Equal(t, 0.0, muzzy.EstimateJaccard(words.Signature(""), words.Signature(" ")))
+	assert.Equal(t, 0.0, muzzy.EstimateJaccard(nil, nil))
+}
+
+func TestLSHIndex(t *testing.T) {
+	splitter := muzzy.NGramSplitter(3, true)
+	lines := corpusLines(t, 500)
+
+	// Every 10th long line is duplicated with a small change.
+	ss := append([]string(nil), lines...)
+	duplicates := map[[2]int]bool{}
+
+	for i := 0; i < len(lines); i += 10 {
+		if len(lines[i]) >= 100 {
+			duplicates[[2]int{i, len(ss)}] = true
+			ss = append(ss, lines[i]+".")
+		}
+	}
+
+	bands, rows := 20, 5
+	threshold := 0.5
+
+	index := muzzy.NewLSHIndex(splitter, bands, rows, 42)
+	index.Add(ss[:100]...)
+	index.Add(ss[100:]...)
+	require.Equal(t, len(ss), index.Len())
+	assert.Equal(t, ss[1], index.Get(1))
+	assert.Equal(t, "", index.Get(len(ss)))
+	assert.InDelta(t, 0.549, muzzy.LSHThreshold(bands, rows), 1e-3)
+
+	pairs := index.Pairs(threshold)
+	found := map[[2]int]float64{}
+
+	for _, pair := range pairs {
+		assert.True(t, pair.A < pair.B)
+		assert.True(t, pair.Jaccard >= threshold)
+		found[[2]int{pair.A, pair.B}] = pair.Jaccard
+	}
+
+	require.NotEmpty(t, duplicates)
+
+	for pair := range duplicates {
+		assert.Contains(t, found, pair, ss[pair[0]])
+	}
+
+	// Pairs are the same as in brute force over pairs sharing a band.
+	m := muzzy.NewMinHash(splitter, bands*rows, 42)
+	signatures := make([][]uint64, len(ss))
+
+	for i, s := range ss {
+		signatures[i] = m.Signature(s)
+	}
+
+	expected := 0
+
+	for a := range ss {
+		for b := a + 1; b < len(ss); b++ {
+			if muzzy.EstimateJaccard(signatures[a], signatures[b]) < threshold {
+				continue
+			}
+
+			for band := 0; band < bands; band++ {
+				lo, hi := band*rows, (band+1)*rows
+				if assert.ObjectsAreEqual(signatures[a][lo:hi], signatures[b][lo:hi]) {
+					expected++
+
+					assert.Contains(t, found, [2]int{a, b})
+
+					break
+				}
+			}
+		}
+	}
+
+	assert.Equal(t, expected, len(pairs))
+
+	for i := 1; i < len(pairs); i++ {
+		assert.True(t, pairs[i-1].Jaccard >= pairs[i].Jaccard)
+	}
+}
+
+func BenchmarkLSHIndex(b *testing.B) {
+	lines := corpusLines(b, 1000)
+	index := muzzy.NewLSHIndex(muzzy.NGramSplitter(3, true), 20, 5, 42)
+	index.Add(lines...)
+
+	b.Run("Signature", func(b *testing.B) {
+		m := muzzy.NewMinHash(muzzy.NGramSplitter(3, true), 100, 42)
+
+		for i := 0; i < b.N; i++ {
+			m.Signature(lines[i%len(lines)])
+		}
+	})
+
+	b.Run("Pairs", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			index.Pairs(0.5)
+		}
+	})
+}
@@ -1,8 +1,11 @@
 package muzzy
 
 import (
+	"context"
 	"math"
+	"sort"
 	"strings"
+	"sync"
 )
 
 const defaultNGramSize = 3
@@ -38,7 +41,7 @@ func (fn SplitterFunc) Similarity(a, b string) float64 {
 	agrams := fn.Split(a)
 	bgrams := fn.Split(b)
 	set := map[string]struct{}{}
-	intersection := float64(0)
+	intersection := 0
 
 	for _, agram := range agrams {
 		set[agram] = struct{}{}
@@ -50,90 +53,766 @@ func (fn SplitterFunc) Similarity(a, b string) float64 {
 		}
 	}
 
-	return intersection / math.Sqrt(float64(len(agrams)*len(bgrams)))
+	return OtsukaOchiai(intersection, len(agrams), len(bgrams))
+}
+
+// Coefficient calculate similarity of two sets of n-grams
+//
+// Parameter `common` is a size of sets intersection, `n1` and `n2` are sizes
+// of sets. Coefficient should return number between 0 and 1.
+type Coefficient func(common, n1, n2 int) float64
+
+// OtsukaOchiai coefficient is a cosine similarity of sets.
+func OtsukaOchiai(common, n1, n2 int) float64 {
+	if n1 == 0 || n2 == 0 {
+		return 0
+	}
+
+	return float64(common) / math.Sqrt(float64(n1)*float64(n2))
+}
+
+// Jaccard coefficient is a size of sets intersection divided by size of union.
+func Jaccard(common, n1, n2 int) float64 {
+	if n1+n2 == common {
+		return 0
+	}
+
+	return float64(common) / float64(n1+n2-common)
+}
+
+// Dice coefficient is a doubled size of sets intersection divided by sum of
+// sets sizes.
+func Dice(common, n1, n2 int) float64 {
+	if n1+n2 == 0 {
+		return 0
+	}
+
+	return float64(2*common) / float64(n1+n2)
 }
 
 // NGramSplitter is a simple n-gram splitter.
 func NGramSplitter(n int, withPadding bool) Splitter {
-	return SplitterFunc(func(s string) []string {
-		if withPadding {
-			padding := strings.Repeat(" ", n-1)
-			s = padding + s + padding
-		}
-		runes := []rune(s)
-		res := make([]string, len(runes)-n+1)
-		for i := 0; i <= len(runes)-n; i++ {
-			res[i] = string(runes[i : i+n])
-		}
+	return nGramSplitter{
+		SplitterFunc: func(s string) []string {
+			if withPadding {
+				padding := strings.Repeat(" ", n-1)
+				s = padding + s + padding
+			}
+			runes := []rune(s)
+			res := make([]string, len(runes)-n+1)
+			for i := 0; i <= len(runes)-n; i++ {
+				res[i] = string(runes[i : i+n])
+			}
 
-		return res
-	})
+			return res
+		},
+		n:           n,
+		withPadding: withPadding,
+	}
+}
+
+// nGramSplitter keep configuration of n-gram splitter to marshal it with
+// index.
+type nGramSplitter struct {
+	SplitterFunc
+	n           int
+	withPadding bool
 }
 
-// SplitIndex index to search string in indexed strings with n-grams.
+// SplitIndex index to search string in indexed strings with n-grams
+//
+// SplitIndex is safe for concurrent use: searches run in parallel and
+// modifications are serialized with them.
 type SplitIndex struct {
 	Splitter
-	index   map[string][]int
-	strings []string
+	indexOptions
+	mu       sync.RWMutex
+	grams    map[string]uint32
+	postings []postingList
+	// Number of not removed strings containing n-gram by its id.
+	frequencies []int
+	exact       map[string][]int
+	strings     []string
+	payloads    []interface{}
+	sizes       []int
+	norms       []float64
+	repeated    []map[string]int
+	removed     []bool
+	// Number and total size of not removed strings.
+	live, sizeSum int
+}
+
+// IndexOption configure SplitIndex.
+type IndexOption func(*indexOptions)
+
+type indexOptions struct {
+	coefficient  Coefficient
+	normalize    func(string) string
+	maxFrequency float64
+	idf          *IDFModel
+	bm25         *bm25
+}
+
+func newIndexOptions(options []IndexOption) indexOptions {
+	opts := indexOptions{
+		coefficient: OtsukaOchiai,
+	}
+
+	for _, option := range options {
+		option(&opts)
+	}
+
+	return opts
+}
+
+// WithCoefficient set coefficient to score search results (Otsuka-Ochiai by
+// default).
+func WithCoefficient(coefficient Coefficient) IndexOption {
+	return func(opts *indexOptions) {
+		opts.coefficient = coefficient
+	}
+}
+
+// WithNormalizer set function to normalize strings before exact matching,
+// for example strings.ToLower. N-grams are not affected by normalizer.
+func WithNormalizer(normalize func(string) string) IndexOption {
+	return func(opts *indexOptions) {
+		opts.normalize = normalize
+	}
+}
+
+// WithStopGrams make n-grams contained in more than maxFrequency share of
+// indexed strings stop-grams
+//
+// Stop-grams are excluded from queries: their posting lists are not read and
+// they are counted neither in common n-grams nor in size of the query, while
+// sizes of indexed strings still include them. So scores are lower than
+// without the option and strings sharing only stop-grams with the query are
+// not found. If every n-gram of the query is a stop-gram, none of them is
+// excluded. Removed strings are counted neither in frequencies of n-grams nor
+// in number of indexed strings.
+func WithStopGrams(maxFrequency float64) IndexOption {
+	return func(opts *indexOptions) {
+		opts.maxFrequency = maxFrequency
+	}
+}
+
+func (opts *indexOptions) normalizeString(s string) string {
+	if opts.normalize == nil {
+		return s
+	}
+
+	return opts.normalize(s)
 }
 
 // NewSplitIndex is a constructor.
-func NewSplitIndex(splitter Splitter) *SplitIndex {
+func NewSplitIndex(splitter Splitter, options ...IndexOption) *SplitIndex {
 	return &SplitIndex{
-		Splitter: splitter,
-		index:    map[string][]int{},
+		Splitter:     splitter,
+		indexOptions: newIndexOptions(options),
+		grams:        map[string]uint32{},
+		exact:        map[string][]int{},
 	}
 }
 
 // Add string to index.
 func (index *SplitIndex) Add(ss ...string) {
-	n := len(index.strings)
-	index.strings = append(index.strings, ss...)
+	index.add(ss, make([]interface{}, len(ss)))
+}
+
+// AddWithPayload add string with arbitrary payload to index and return index
+// of the string. Payload is returned with hits of the string, for example it
+// may be a key of the string in database.
+func (index *SplitIndex) AddWithPayload(s string, payload interface{}) int {
+	return index.add([]string{s}, []interface{}{payload})
+}
+
+// Add strings with payloads and return index of the first one.
+func (index *SplitIndex) add(ss []string, payloads []interface{}) int {
+	return index.insert(index.prepare(ss, payloads))
+}
+
+// Add strings, missing ones take their indexes as removed strings, and return
+// index of the first one.
+func (index *SplitIndex) addMissing(ss []string, missing []bool) int {
+	b := index.prepare(ss, make([]interface{}, len(ss)))
+	b.missing = missing
+
+	return index.insert(b)
+}
+
+// batch of strings split and normalized out of index lock.
+type batch struct {
+	strings    []string
+	payloads   []interface{}
+	split      [][]string
+	normalized []string
+	norms      []float64
+	repeated   []map[string]int
+	// Strings to be added as removed or nil.
+	missing []bool
+}
+
+func (index *SplitIndex) prepare(ss []string, payloads []interface{}) *batch {
+	b := &batch{
+		strings:    ss,
+		payloads:   payloads,
+		split:      make([][]string, len(ss)),
+		normalized: make([]string, len(ss)),
+	}
 
 	for i, s := range ss {
-		ngrams := index.Split(s)
+		b.split[i] = index.Split(s)
+		b.normalized[i] = index.normalizeString(s)
+	}
+
+	if index.idf != nil {
+		b.norms = make([]float64, len(ss))
+		for i, ngrams := range b.split {
+			b.norms[i] = index.idf.norm(ngrams)
+		}
+	}
+
+	if index.bm25 != nil {
+		b.repeated = make([]map[string]int, len(ss))
+		for i, s := range ss {
+			b.repeated[i] = repeatedGrams(index.Splitter, s)
+		}
+	}
+
+	return b
+}
+
+// Insert prepared batch and return index of the first string.
+func (index *SplitIndex) insert(b *batch) int {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	n := len(index.strings)
+	index.strings = append(index.strings, b.strings...)
+	index.payloads = append(index.payloads, b.payloads...)
+	index.norms = append(index.norms, b.norms...)
+	index.repeated = append(index.repeated, b.repeated...)
+
+	for i, ngrams := range b.split {
 		k := n + i
 
+		// Missing strings are not counted and have no postings.
+		if b.missing != nil && b.missing[i] {
+			index.sizes = append(index.sizes, 0)
+			index.removed = append(index.removed, true)
+
+			continue
+		}
+
+		index.live++
+		index.sizes = append(index.sizes, len(ngrams))
+		index.sizeSum += len(ngrams)
+		index.removed = append(index.removed, false)
+		index.exact[b.normalized[i]] = append(index.exact[b.normalized[i]], k)
+
 		for _, ngram := range ngrams {
-			index.index[ngram] = append(index.index[ngram], k)
+			id := index.gramID(ngram)
+			index.postings[id].Append(k)
+			index.frequencies[id]++
 		}
 	}
+
+	return n
+}
+
+// Return id of n-gram, new n-grams get next id.
+func (index *SplitIndex) gramID(ngram string) uint32 {
+	id, ok := index.grams[ngram]
+	if !ok {
+		id = uint32(len(index.postings))
+		index.grams[ngram] = id
+		index.postings = append(index.postings, postingList{})
+		index.frequencies = append(index.frequencies, 0)
+	}
+
+	return id
 }
 
 // Get string by index.
 func (index *SplitIndex) Get(i int) string {
-	if i < 0 || i >= len(index.strings) {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	if !index.alive(i) {
 		return ""
 	}
 
 	return index.strings[i]
 }
 
+// Payload of the string with given index.
+func (index *SplitIndex) Payload(i int) interface{} {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	if !index.alive(i) {
+		return nil
+	}
+
+	return index.payloads[i]
+}
+
+// Remove string from index
+//
+// String is marked as removed and never be found again, but its index is not
+// reused and indexes of other strings stay the same. Posting lists keep the
+// removed string until Compact is called. Return false if there is no string
+// with such index.
+func (index *SplitIndex) Remove(i int) bool {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	if !index.alive(i) {
+		return false
+	}
+
+	for _, ngram := range index.Split(index.strings[i]) {
+		if id, ok := index.grams[ngram]; ok {
+			index.frequencies[id]--
+		}
+	}
+
+	index.removeExact(i)
+	index.removed[i] = true
+	index.strings[i] = ""
+	index.payloads[i] = nil
+	index.live--
+	index.sizeSum -= index.sizes[i]
+	index.sizes[i] = 0
+
+	return true
+}
+
+// Update string with given index
+//
+// Payload of the string is kept. Posting lists are updated immediately.
+// Return false if there is no string with such index.
+func (index *SplitIndex) Update(i int, s string) bool {
+	ngrams := index.Split(s)
+	normalized := index.normalizeString(s)
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	if !index.alive(i) {
+		return false
+	}
+
+	for _, ngram := range index.Split(index.strings[i]) {
+		if id, ok := index.grams[ngram]; ok {
+			index.postings[id].Remove(i)
+			index.frequencies[id]--
+		}
+	}
+
+	for _, ngram := range ngrams {
+		id := index.gramID(ngram)
+		index.postings[id].Insert(i)
+		index.frequencies[id]++
+	}
+
+	index.removeExact(i)
+	index.exact[normalized] = insertIndex(index.exact[normalized], i)
+
+	index.strings[i] = s
+	index.sizeSum += len(ngrams) - index.sizes[i]
+	index.sizes[i] = len(ngrams)
+
+	if index.idf != nil {
+		index.norms[i] = index.idf.norm(ngrams)
+	}
+
+	if index.bm25 != nil {
+		index.repeated[i] = repeatedGrams(index.Splitter, s)
+	}
+
+	return true
+}
+
+// Compact drop removed strings from posting lists to reclaim memory.
+func (index *SplitIndex) Compact() {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	grams := make(map[string]uint32, len(index.grams))
+	postings := make([]postingList, 0, len(index.postings))
+	frequencies := make([]int, 0, len(index.postings))
+	alive := func(i int) bool { return !index.removed[i] }
+
+	for ngram, id := range index.grams {
+		list := index.postings[id]
+		list.Filter(alive)
+
+		if list.Len() > 0 {
+			grams[ngram] = uint32(len(postings))
+			postings = append(postings, list)
+			frequencies = append(frequencies, index.frequencies[id])
+		}
+	}
+
+	index.grams = grams
+	index.postings = postings
+	index.frequencies = frequencies
+}
+
+// GramFrequency is a number of indexed strings containing n-gram.
+type GramFrequency struct {
+	Gram      string
+	Frequency int
+}
+
+// GramFrequencies return frequencies of all indexed n-grams
+//
+// N-grams are sorted by frequency in descending order, n-grams with equal
+// frequency are sorted lexicographically. Removed strings are not counted.
+func (index *SplitIndex) GramFrequencies() []GramFrequency {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	return index.gramFrequencies(0)
+}
+
+// StopGrams return n-grams contained in more than share of not removed
+// strings sorted as GramFrequencies. Search skips them with
+// WithStopGrams(share).
+func (index *SplitIndex) StopGrams(share float64) []GramFrequency {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	return index.gramFrequencies(share * float64(index.live))
+}
+
+func (index *SplitIndex) gramFrequencies(limit float64) []GramFrequency {
+	var frequencies []GramFrequency
+
+	for ngram, id := range index.grams {
+		if n := index.frequencies[id]; n > 0 && float64(n) > limit {
+			frequencies = append(frequencies, GramFrequency{Gram: ngram, Frequency: n})
+		}
+	}
+
+	sort.Slice(frequencies, func(i, j int) bool {
+		if frequencies[i].Frequency != frequencies[j].Frequency {
+			return frequencies[i].Frequency > frequencies[j].Frequency
+		}
+
+		return frequencies[i].Gram < frequencies[j].Gram
+	})
+
+	return frequencies
+}
+
+func (index *SplitIndex) removeExact(i int) {
+	normalized := index.normalizeString(index.strings[i])
+
+	ids := index.exact[normalized]
+	for k := range ids {
+		if ids[k] == i {
+			ids = append(ids[:k], ids[k+1:]...)
+			break
+		}
+	}
+
+	if len(ids) == 0 {
+		delete(index.exact, normalized)
+	} else {
+		index.exact[normalized] = ids
+	}
+}
+
+func insertIndex(ids []int, i int) []int {
+	k := sort.SearchInts(ids, i)
+	ids = append(ids, 0)
+	copy(ids[k+1:], ids[k:])
+	ids[k] = i
+
+	return ids
+}
+
+func (index *SplitIndex) alive(i int) bool {
+	return i >= 0 && i < len(index.strings) && !index.removed[i]
+}
+
+// Hit is a search result.
+type Hit struct {
+	// Index of the string in index.
+	Index int
+	// String is the indexed string.
+	String string
+	// Score is a similarity of the string to the query.
+	Score float64
+	// Exact is true if the string is equal to the query (after
+	// normalization).
+	Exact bool
+	// Payload of the string.
+	Payload interface{}
+}
+
 // Search index of maximal similar string in index
 //
 // Return -1 if no string n-gram found in index.
 func (index *SplitIndex) Search(s string) int {
-	for i := range index.strings {
-		if index.strings[i] == s {
-			return i
+	hit, _ := index.Find(s)
+
+	return hit.Index
+}
+
+// Find string equal to s or maximal similar string
+//
+// Equal strings are found by hash of normalized string, so exact matching does
+// not depend on index size. If there are several equal strings, the one with
+// minimal index is returned. Otherwise the best hit of SearchTopK is returned
+// with Exact equal false. Return false if no string n-gram found in index.
+func (index *SplitIndex) Find(s string) (Hit, bool) {
+	ngrams := index.Split(s)
+	q := index.query(s, ngrams)
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	if ids := index.exact[q.normalized]; len(ids) > 0 {
+		i := ids[0]
+
+		q.prepare()
+
+		return Hit{
+			Index:   i,
+			String:  index.strings[i],
+			Payload: index.payloads[i],
+			Score:   q.score(i, index.common(q, i)),
+			Exact:   true,
+		}, true
+	}
+
+	hits := q.TopK(1)
+	if len(hits) == 0 {
+		return Hit{Index: -1}, false
+	}
+
+	return hits[0], true
+}
+
+// Count n-grams of i-th string shared with the query as query.count do.
+// Without normalizer exact string contains all n-grams of the query.
+func (index *SplitIndex) common(q *query, i int) float64 {
+	set := map[string]struct{}{}
+
+	if index.normalize != nil {
+		for _, ngram := range index.Split(index.strings[i]) {
+			set[ngram] = struct{}{}
 		}
 	}
 
-	ngrams := index.Split(s)
-	counters := map[int]int{}
+	common := 0.0
 
-	for _, ngram := range ngrams {
-		for _, i := range index.index[ngram] {
-			counters[i]++
+	for _, ngram := range q.ngrams {
+		if _, ok := set[ngram]; ok || index.normalize == nil {
+			common += q.postingWeight(q.weight(ngram), i, index.tf(ngram, i))
 		}
 	}
 
-	maxIndex, maxCount := -1, -1
-	for i, count := range counters {
-		if count > maxCount {
-			maxIndex, maxCount = i, count
+	return common
+}
+
+// SearchTopK return up to k strings most similar to s
+//
+// Similarity is calculated by index coefficient over sets of n-grams, so
+// score of every hit is equal to the splitter similarity when Otsuka-Ochiai
+// coefficient is used. Hits are sorted by score in descending order, strings
+// with equal score are sorted by index, so the result does not depend on map
+// iteration order.
+func (index *SplitIndex) SearchTopK(s string, k int) []Hit {
+	hits, _ := index.SearchTopKContext(context.Background(), s, k)
+
+	return hits
+}
+
+// SearchTopKContext is a SearchTopK, that stop search on context cancellation
+// and return context error.
+func (index *SplitIndex) SearchTopKContext(ctx context.Context, s string, k int) ([]Hit, error) {
+	if k <= 0 {
+		return nil, nil
+	}
+
+	ngrams := index.Split(s)
+	q := index.query(s, ngrams)
+	q.ctx = ctx
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	return q.result(q.TopK(k))
+}
+
+// SearchThreshold return all strings with similarity to s great or equal to
+// threshold
+//
+// Candidates are pruned with q-gram count filter: string of n-grams set can
+// not reach threshold if it shares less n-grams with the query than minimal
+// number implied by the index coefficient. Hits are sorted as in SearchTopK.
+func (index *SplitIndex) SearchThreshold(s string, threshold float64) []Hit {
+	hits, _ := index.SearchThresholdContext(context.Background(), s, threshold)
+
+	return hits
+}
+
+// SearchThresholdContext is a SearchThreshold, that stop search on context
+// cancellation and return context error.
+func (index *SplitIndex) SearchThresholdContext(ctx context.Context, s string, threshold float64) ([]Hit, error) {
+	ngrams := index.Split(s)
+	q := index.query(s, ngrams)
+	q.ctx = ctx
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	return q.result(q.Threshold(threshold))
+}
+
+// Scores of all strings sharing n-grams with s.
+func (index *SplitIndex) scores(s string) map[int]float64 {
+	q := index.query(s, index.Split(s))
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	return q.Scores()
+}
+
+// SearchRerank return up to n candidates found by SearchTopK re-scored with
+// given algorithm
+//
+// Score of every hit is a Similarity of s and the candidate with given
+// threshold, so distance based algorithms calculate distance with bound and
+// stop early on too different candidates. Candidates with similarity less than
+// threshold are dropped. Hits are sorted by new score.
+func (index *SplitIndex) SearchRerank(s string, n int, algo similarityAlgorithm, threshold float64) []Hit {
+	hits, _ := index.SearchRerankContext(context.Background(), s, n, algo, threshold)
+
+	return hits
+}
+
+// SearchRerankContext is a SearchRerank, that stop search and distance
+// calculation on context cancellation and return context error.
+func (index *SplitIndex) SearchRerankContext(
+	ctx context.Context, s string, n int, algo similarityAlgorithm, threshold float64,
+) ([]Hit, error) {
+	candidates, err := index.SearchTopKContext(ctx, s, n)
+	if err != nil {
+		return nil, err
+	}
+
+	return rerank(ctx, s, candidates, algo, threshold)
+}
+
+func rerank(
+	ctx context.Context, s string, candidates []Hit, algo similarityAlgorithm, threshold float64,
+) ([]Hit, error) {
+	hits := candidates[:0]
+
+	for _, hit := range candidates {
+		score, err := similarity(ctx, s, hit.String, algo, threshold)
+		if err != nil {
+			return nil, err
 		}
+
+		if hit.Score = score; score > 0 || threshold <= 0 {
+			hits = append(hits, hit)
+		}
+	}
+
+	sortHits(hits)
+
+	return hits, nil
+}
+
+func (index *SplitIndex) query(s string, ngrams []string) *query {
+	return &query{
+		indexOptions: &index.indexOptions,
+		source:       index,
+		ngrams:       ngrams,
+		normalized:   index.normalizeString(s),
+	}
+}
+
+func (index *SplitIndex) eachPosting(ngram string, fn func(i, tf int) bool) {
+	id, ok := index.grams[ngram]
+	if !ok {
+		return
 	}
 
-	return maxIndex
+	index.postings[id].EachWhile(func(i int) bool {
+		return index.removed[i] || fn(i, index.tf(ngram, i))
+	})
+}
+
+func (index *SplitIndex) eachPostingOf(ngram string, ids []int, fn func(i, tf int) bool) {
+	id, ok := index.grams[ngram]
+	if !ok {
+		return
+	}
+
+	index.postings[id].EachOf(ids, func(i int) bool {
+		return index.removed[i] || fn(i, index.tf(ngram, i))
+	})
+}
+
+func (index *SplitIndex) frequency(ngram string) int {
+	id, ok := index.grams[ngram]
+	if !ok {
+		return 0
+	}
+
+	return index.frequencies[id]
+}
+
+func (index *SplitIndex) total() int {
+	return len(index.strings)
+}
+
+func (index *SplitIndex) liveTotal() int {
+	return index.live
+}
+
+func (index *SplitIndex) size(i int) int {
+	return index.sizes[i]
+}
+
+// Repeated n-grams are kept only with BM25 ranking.
+func (index *SplitIndex) tf(ngram string, i int) int {
+	if index.repeated == nil {
+		return 1
+	}
+
+	if n, ok := index.repeated[i][ngram]; ok {
+		return n
+	}
+
+	return 1
+}
+
+func (index *SplitIndex) avgSize() float64 {
+	if index.live == 0 {
+		return 0
+	}
+
+	return float64(index.sizeSum) / float64(index.live)
+}
+
+func (index *SplitIndex) norm(i int) float64 {
+	return index.norms[i]
+}
+
+func (index *SplitIndex) text(i int) string {
+	return index.strings[i]
+}
+
+func (index *SplitIndex) payload(i int) interface{} {
+	return index.payloads[i]
 }
@@ -0,0 +1,63 @@
+//go:build go1.13
+// +build go1.13
+
+package muzzy_test
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/vporoshok/muzzy"
+)
+
+// Heap retained by build, measured after garbage collection.
+func retainedHeap(b *testing.B, build func() interface{}) {
+	var before, after runtime.MemStats
+
+	retained := int64(0)
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		b.StartTimer()
+
+		index := build()
+
+		b.StopTimer()
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		runtime.KeepAlive(index)
+		b.StartTimer()
+
+		// Heap may shrink, if garbage of previous iterations is collected.
+		retained += int64(after.HeapAlloc) - int64(before.HeapAlloc)
+	}
+
+	b.ReportMetric(float64(retained)/float64(b.N), "retained-B/op")
+}
+
+// BenchmarkSplitIndexMemory compare heap retained by SplitIndex of corpus
+// with mapIndex.
+func BenchmarkSplitIndexMemory(b *testing.B) {
+	lines := readCorpusLines(b)
+	splitter := muzzy.NGramSplitter(3, true)
+
+	b.Run("SplitIndex", func(b *testing.B) {
+		retainedHeap(b, func() interface{} {
+			index := muzzy.NewSplitIndex(splitter)
+			index.Add(lines...)
+
+			return index
+		})
+	})
+
+	b.Run("Map", func(b *testing.B) {
+		retainedHeap(b, func() interface{} {
+			index := newMapIndex(splitter)
+			index.Add(lines...)
+
+			return index
+		})
+	})
+}
@@ -1,11 +1,16 @@
 package muzzy_test
 
 import (
-	"io/ioutil"
-	"path/filepath"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"github.com/vporoshok/muzzy"
 )
@@ -13,16 +18,14 @@ import (
 type SplitIndexSuite struct {
 	suite.Suite
 	index *muzzy.SplitIndex
+	lines []string
 }
 
 func (s *SplitIndexSuite) SetupSuite() {
-	corpus, err := ioutil.ReadFile(filepath.Join("testdata", "dead_souls.txt"))
-	s.Require().NoError(err)
-
-	lines := strings.Split(string(corpus), "\n")
+	s.lines = readCorpusLines(s.T())
 	splitter := muzzy.NGramSplitter(3, true)
 	s.index = muzzy.NewSplitIndex(splitter)
-	s.index.Add(lines...)
+	s.index.Add(s.lines...)
 }
 
 func (s *SplitIndexSuite) Test() {
@@ -66,3 +69,474 @@ func (s *SplitIndexSuite) Test() {
 func TestSplitIndex(t *testing.T) {
 	suite.Run(t, new(SplitIndexSuite))
 }
+
+func (s *SplitIndexSuite) TestTopK() {
+	hits := s.index.SearchTopK(`"Что ж баирн? у себя, что ли?"`, 5)
+	s.Require().Len(hits, 5)
+	s.Equal(`"Что ж барин? у себя, что ли?"`, hits[0].String)
+
+	for i := 1; i < len(hits); i++ {
+		s.True(hits[i-1].Score >= hits[i].Score)
+	}
+
+	s.Equal(hits, s.index.SearchTopK(`"Что ж баирн? у себя, что ли?"`, 5))
+	s.Empty(s.index.SearchTopK("not found", 5))
+	s.Empty(s.index.SearchTopK("барин", 0))
+}
+
+func (s *SplitIndexSuite) TestScore() {
+	splitter := muzzy.NGramSplitter(3, true)
+	query := "Чичиков"
+
+	for _, hit := range s.index.SearchTopK(query, 10) {
+		s.InDelta(splitter.Similarity(query, hit.String), hit.Score, 1e-9)
+	}
+}
+
+func (s *SplitIndexSuite) TestThreshold() {
+	splitter := muzzy.NGramSplitter(3, true)
+	query := `"Что ж баирн? у себя?"`
+	threshold := 0.4
+
+	var expected []int
+
+	for i, line := range s.lines {
+		if splitter.Similarity(query, line) >= threshold {
+			expected = append(expected, i)
+		}
+	}
+
+	hits := s.index.SearchThreshold(query, threshold)
+	actual := make([]int, 0, len(hits))
+
+	for _, hit := range hits {
+		s.True(hit.Score >= threshold)
+		actual = append(actual, hit.Index)
+	}
+
+	s.NotEmpty(actual)
+	s.ElementsMatch(expected, actual)
+	s.Empty(s.index.SearchThreshold(query, 1.1))
+}
+
+func (s *SplitIndexSuite) TestRerank() {
+	query := `"Что ж баирн? у себя, что ли?"`
+
+	hits := s.index.SearchRerank(query, 20, muzzy.DamerauLevenshtein, 0.5)
+	s.Require().NotEmpty(hits)
+	s.Equal(`"Что ж барин? у себя, что ли?"`, hits[0].String)
+
+	for i, hit := range hits {
+		s.InDelta(muzzy.Similarity(query, hit.String, muzzy.DamerauLevenshtein, 0.5), hit.Score, 1e-9)
+		s.True(hit.Score >= 0.5)
+
+		if i > 0 {
+			s.True(hits[i-1].Score >= hit.Score)
+		}
+	}
+
+	s.Len(s.index.SearchRerank(query, 20, muzzy.JaroWinkler, 0), 20)
+	s.Empty(s.index.SearchRerank(query, 0, muzzy.Levenshtein, 0))
+}
+
+func (s *SplitIndexSuite) TestContext() {
+	query := `"Что ж баирн? у себя, что ли?"`
+
+	hits, err := s.index.SearchTopKContext(context.Background(), query, 10)
+	s.NoError(err)
+	s.Equal(s.index.SearchTopK(query, 10), hits)
+
+	hits, err = s.index.SearchThresholdContext(context.Background(), query, 0.3)
+	s.NoError(err)
+	s.Equal(s.index.SearchThreshold(query, 0.3), hits)
+
+	hits, err = s.index.SearchRerankContext(context.Background(), query, 10, muzzy.Levenshtein, 0.5)
+	s.NoError(err)
+	s.Equal(s.index.SearchRerank(query, 10, muzzy.Levenshtein, 0.5), hits)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	hits, err = s.index.SearchTopKContext(ctx, query, 10)
+	s.Equal(context.Canceled, err)
+	s.Nil(hits)
+
+	hits, err = s.index.SearchThresholdContext(&expiringContext{Context: context.Background(), n: 3}, query, 0.3)
+	s.Equal(context.Canceled, err)
+	s.Nil(hits)
+
+	hits, err = s.index.SearchRerankContext(ctx, query, 10, muzzy.Levenshtein, 0.5)
+	s.Equal(context.Canceled, err)
+	s.Nil(hits)
+}
+
+func TestSplitIndexCoefficients(t *testing.T) {
+	cases := [...]struct {
+		name        string
+		coefficient muzzy.Coefficient
+		score       float64
+	}{
+		{"Otsuka-Ochiai", muzzy.OtsukaOchiai, 2 / math.Sqrt(8)},
+		{"Jaccard", muzzy.Jaccard, 2.0 / 4},
+		{"Dice", muzzy.Dice, 4.0 / 6},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			index := muzzy.NewSplitIndex(muzzy.NGramSplitter(2, false), muzzy.WithCoefficient(c.coefficient))
+			index.Add("abcde", "xyz")
+
+			hits := index.SearchTopK("abc", 2)
+			if assert.Len(t, hits, 1) {
+				assert.Equal(t, 0, hits[0].Index)
+				assert.InDelta(t, c.score, hits[0].Score, 1e-9)
+			}
+		})
+	}
+}
+
+func TestSplitIndexTies(t *testing.T) {
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(2, false))
+	index.Add("xab", "yab", "zab", "abc")
+
+	for i := 0; i < 10; i++ {
+		hits := index.SearchTopK("ab", 3)
+		if assert.Len(t, hits, 3) {
+			assert.Equal(t, "xab", hits[0].String)
+			assert.Equal(t, "yab", hits[1].String)
+			assert.Equal(t, "zab", hits[2].String)
+		}
+		assert.Equal(t, 0, index.Search("ab"))
+	}
+}
+
+func TestSplitIndexRemoveUpdate(t *testing.T) {
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true))
+	index.Add("milk", "silk", "", "happiness")
+
+	assert.True(t, index.Remove(1))
+	assert.False(t, index.Remove(1))
+	assert.False(t, index.Remove(10))
+	assert.Equal(t, "", index.Get(1))
+	assert.Equal(t, 0, index.Search("silk"))
+	assert.Equal(t, 2, index.Search(""))
+
+	assert.True(t, index.Update(0, "princess"))
+	assert.False(t, index.Update(1, "silk"))
+	assert.Equal(t, "princess", index.Get(0))
+	assert.Equal(t, 0, index.Search("princes"))
+	assert.Equal(t, -1, index.Search("milk"))
+
+	index.Compact()
+	index.Add("silk")
+	assert.Equal(t, 4, index.Search("silk"))
+	assert.Equal(t, 3, index.Search("happines"))
+
+	for _, hit := range index.SearchThreshold("princess", 0) {
+		assert.NotEqual(t, 1, hit.Index)
+	}
+
+	assert.True(t, index.Update(4, "milk"))
+	assert.Equal(t, []muzzy.Hit{{Index: 4, String: "milk", Score: 1, Exact: true}}, index.SearchTopK("milk", 5))
+}
+
+func TestSplitIndexFind(t *testing.T) {
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true), muzzy.WithNormalizer(strings.ToLower))
+	index.Add("Milk", "silk", "milk", "happiness")
+
+	hit, ok := index.Find("MILK")
+	assert.True(t, ok)
+	assert.Equal(t, muzzy.Hit{Index: 0, String: "Milk", Score: 1.0 / 6, Exact: true}, hit)
+
+	index.Remove(0)
+
+	hit, ok = index.Find("MILK")
+	assert.True(t, ok)
+	assert.Equal(t, 2, hit.Index)
+	assert.True(t, hit.Exact)
+
+	index.Update(2, "silky")
+
+	hit, ok = index.Find("milky")
+	assert.True(t, ok)
+	assert.Equal(t, 2, hit.Index)
+	assert.False(t, hit.Exact)
+
+	hits := index.SearchTopK("Silk", 2)
+	if assert.Len(t, hits, 2) {
+		assert.True(t, hits[0].Exact)
+		assert.False(t, hits[1].Exact)
+	}
+
+	hit, ok = index.Find("zzz")
+	assert.False(t, ok)
+	assert.Equal(t, -1, hit.Index)
+	assert.Equal(t, -1, index.Search("zzz"))
+}
+
+func TestSplitIndexPayload(t *testing.T) {
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true))
+	index.Add("milk")
+	assert.Equal(t, 1, index.AddWithPayload("silk", 42))
+	assert.Equal(t, 2, index.AddWithPayload("happiness", "key"))
+
+	hits := index.SearchTopK("silky", 2)
+	if assert.Len(t, hits, 2) {
+		assert.Equal(t, 42, hits[0].Payload)
+		assert.Nil(t, hits[1].Payload)
+	}
+
+	hit, _ := index.Find("happiness")
+	assert.Equal(t, "key", hit.Payload)
+
+	index.Update(1, "silky")
+	assert.Equal(t, 42, index.Payload(1))
+
+	index.Remove(1)
+	assert.Nil(t, index.Payload(1))
+	assert.Nil(t, index.Payload(10))
+}
+
+func (s *SplitIndexSuite) TestStopGrams() {
+	pruned := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true), muzzy.WithStopGrams(0.01))
+	pruned.Add(s.lines...)
+
+	stop := pruned.StopGrams(0.01)
+	s.Require().NotEmpty(stop)
+	s.Equal(pruned.GramFrequencies()[:len(stop)], stop)
+
+	for _, gram := range stop {
+		s.True(float64(gram.Frequency) > 0.01*float64(len(s.lines)), gram.Gram)
+	}
+
+	query := `"Что ж баирн? у себя, что ли?"`
+	hits := pruned.SearchTopK(query, 10)
+	s.Require().NotEmpty(hits)
+	s.Equal(`"Что ж барин? у себя, что ли?"`, hits[0].String)
+
+	for _, hit := range hits {
+		e, ok := pruned.Explain(query, hit.Index)
+		s.True(ok)
+		s.Equal(hit.Score, e.Score)
+	}
+}
+
+func TestSplitIndexAllStopGrams(t *testing.T) {
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(2, false), muzzy.WithStopGrams(0.5))
+	index.Add("ab", "ab", "abc", "ba")
+
+	assert.Equal(t, []muzzy.GramFrequency{{Gram: "ab", Frequency: 3}}, index.StopGrams(0.5))
+	assert.Equal(t, []muzzy.GramFrequency{
+		{Gram: "ab", Frequency: 3},
+		{Gram: "ba", Frequency: 1},
+		{Gram: "bc", Frequency: 1},
+	}, index.GramFrequencies())
+
+	hits := index.SearchTopK("ab", 5)
+	if assert.Len(t, hits, 3) {
+		assert.Equal(t, 0, hits[0].Index)
+		assert.Equal(t, 1, hits[1].Index)
+		assert.Equal(t, 2, hits[2].Index)
+	}
+
+	// Stop-gram "ab" is excluded from the query, so strings sharing only it
+	// with the query are not found.
+	hits = index.SearchTopK("abc", 5)
+	if assert.Len(t, hits, 1) {
+		assert.Equal(t, 2, hits[0].Index)
+		assert.InDelta(t, 1/math.Sqrt(2), hits[0].Score, 1e-9)
+		assert.True(t, hits[0].Exact)
+	}
+
+	e, _ := index.Explain("abc", 2)
+	assert.Equal(t, []muzzy.GramExplanation{
+		{Gram: "ab", Matched: true, Stop: true, Frequency: 3},
+		{Gram: "bc", Matched: true, Frequency: 1},
+	}, e.Grams)
+	assert.Equal(t, hits[0].Score, e.Score)
+
+	hit, ok := index.Find("abc")
+	assert.True(t, ok)
+	assert.Equal(t, hits[0], hit)
+
+	assert.Empty(t, index.SearchTopK("abx", 5))
+
+	// Removed strings are not counted, so "ab" is still contained in more
+	// than half of strings.
+	index.Add("cd", "cd")
+	index.Remove(4)
+	index.Remove(5)
+	assert.Equal(t, []muzzy.GramFrequency{{Gram: "ab", Frequency: 3}}, index.StopGrams(0.5))
+	assert.Len(t, index.SearchTopK("abc", 5), 1)
+
+	path := writeDiskIndex(t, index)
+	defer os.Remove(path)
+
+	disk, err := muzzy.OpenDiskIndex(path, nil, muzzy.WithStopGrams(0.5))
+	if assert.NoError(t, err) {
+		assert.Equal(t, index.SearchTopK("ab", 5), disk.SearchTopK("ab", 5))
+		assert.Equal(t, index.SearchTopK("abc", 5), disk.SearchTopK("abc", 5))
+
+		hit, _ = disk.Find("abc")
+		assert.Equal(t, hits[0], hit)
+		assert.NoError(t, disk.Close())
+	}
+}
+
+func TestSplitIndexConcurrency(t *testing.T) {
+	index := muzzy.NewSplitIndex(muzzy.NGramSplitter(3, true))
+	index.Add("milk", "silk")
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < 100; i++ {
+				index.Add(fmt.Sprintf("silk %d-%d", w, i))
+			}
+		}(w)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < 100; i++ {
+				assert.Equal(t, 1, index.Search("silk"))
+				assert.NotEmpty(t, index.SearchTopK("silk", 5))
+				assert.NotEmpty(t, index.SearchThreshold("milk", 0.5))
+			}
+		}()
+	}
+
+	wg.Wait()
+	assert.Equal(t, "silk 0-0", index.Get(index.Search("silk 0-0")))
+}
+
+// mapIndex is the layout of SplitIndex before gram ids and packed postings:
+// map of n-grams to slices of indexes, which are counted in a map on search.
+type mapIndex struct {
+	muzzy.Splitter
+	mu      sync.RWMutex
+	index   map[string][]int
+	strings []string
+	sizes   []int
+	removed []bool
+}
+
+func newMapIndex(splitter muzzy.Splitter) *mapIndex {
+	return &mapIndex{Splitter: splitter, index: map[string][]int{}}
+}
+
+func (index *mapIndex) Add(ss ...string) {
+	split := make([][]string, len(ss))
+	for i, s := range ss {
+		split[i] = index.Split(s)
+	}
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	n := len(index.strings)
+	index.strings = append(index.strings, ss...)
+
+	for i, ngrams := range split {
+		index.sizes = append(index.sizes, len(ngrams))
+		index.removed = append(index.removed, false)
+
+		for _, ngram := range ngrams {
+			index.index[ngram] = append(index.index[ngram], n+i)
+		}
+	}
+}
+
+func (index *mapIndex) SearchTopK(s string, k int) []muzzy.Hit {
+	ngrams := index.Split(s)
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	counters := map[int]int{}
+
+	for _, ngram := range ngrams {
+		for _, i := range index.index[ngram] {
+			if !index.removed[i] {
+				counters[i]++
+			}
+		}
+	}
+
+	hits := make([]muzzy.Hit, 0, len(counters))
+	for i, count := range counters {
+		score := muzzy.OtsukaOchiai(count, len(ngrams), index.sizes[i])
+		hits = append(hits, muzzy.Hit{Index: i, String: index.strings[i], Score: score})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+
+		return hits[i].Index < hits[j].Index
+	})
+
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+
+	return hits
+}
+
+// BenchmarkSplitIndex compare SplitIndex with mapIndex.
+func BenchmarkSplitIndex(b *testing.B) {
+	lines := readCorpusLines(b)
+	splitter := muzzy.NGramSplitter(3, true)
+	query := `"Что ж баирн? у себя, что ли?"`
+
+	b.Run("Add", func(b *testing.B) {
+		b.Run("SplitIndex", func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				index := muzzy.NewSplitIndex(splitter)
+				index.Add(lines...)
+			}
+		})
+
+		b.Run("Map", func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				index := newMapIndex(splitter)
+				index.Add(lines...)
+			}
+		})
+	})
+
+	b.Run("Search", func(b *testing.B) {
+		index := muzzy.NewSplitIndex(splitter)
+		index.Add(lines...)
+
+		old := newMapIndex(splitter)
+		old.Add(lines...)
+
+		b.Run("SplitIndex", func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				index.SearchTopK(query, 5)
+			}
+		})
+
+		b.Run("Map", func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				old.SearchTopK(query, 5)
+			}
+		})
+	})
+}
@@ -0,0 +1,194 @@
+package muzzy
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// Number of postings in a block between skip pointers.
+const skipInterval = 64
+
+// postingList is a sorted list of strings indexes packed as varint encoded
+// deltas. Most deltas are small, so posting takes one or two bytes instead of
+// eight. Every skipInterval postings a skip pointer is kept, so blocks of
+// postings may be skipped without decoding.
+type postingList struct {
+	data  []byte
+	skips []postingSkip
+	last  uint32
+	count uint32
+}
+
+// postingSkip point to a block of postings.
+type postingSkip struct {
+	// The last index before the block.
+	last uint32
+	// Offset of the block in data.
+	offset uint32
+}
+
+func newPostingList(indexes []int) postingList {
+	var list postingList
+
+	for _, i := range indexes {
+		list.Append(i)
+	}
+
+	return list
+}
+
+// Len return number of postings.
+func (list *postingList) Len() int {
+	return int(list.count)
+}
+
+// Append index greater than all indexes in list.
+func (list *postingList) Append(i int) {
+	var buf [binary.MaxVarintLen32]byte
+
+	if list.count > 0 && list.count%skipInterval == 0 {
+		list.skips = append(list.skips, postingSkip{last: list.last, offset: uint32(len(list.data))})
+	}
+
+	n := binary.PutUvarint(buf[:], uint64(uint32(i)-list.last))
+	list.data = append(list.data, buf[:n]...)
+	list.last = uint32(i)
+	list.count++
+}
+
+// Each call fn for every index in ascending order.
+func (list *postingList) Each(fn func(int)) {
+	list.EachWhile(func(i int) bool {
+		fn(i)
+		return true
+	})
+}
+
+// EachWhile call fn for every index in ascending order until fn return false.
+func (list *postingList) EachWhile(fn func(int) bool) {
+	data := list.data
+	last := uint32(0)
+
+	for len(data) > 0 {
+		delta, n := binary.Uvarint(data)
+		data = data[n:]
+		last += uint32(delta)
+
+		if !fn(int(last)) {
+			return
+		}
+	}
+}
+
+// EachOf call fn for every index of ascending ids contained in list until fn
+// return false.
+func (list *postingList) EachOf(ids []int, fn func(int) bool) {
+	skip := func(k int) postingSkip { return list.skips[k] }
+
+	seekPostings(list.data, len(list.skips), skip, ids, maxInt, func(_, i int) bool {
+		return fn(i)
+	})
+}
+
+// Indexes unpack list.
+func (list *postingList) Indexes() []int {
+	res := make([]int, 0, list.count)
+	list.Each(func(i int) {
+		res = append(res, i)
+	})
+
+	return res
+}
+
+// Insert index keeping list sorted. List is repacked, so Append should be
+// preferred.
+func (list *postingList) Insert(i int) {
+	if list.count == 0 || uint32(i) > list.last {
+		list.Append(i)
+		return
+	}
+
+	indexes := list.Indexes()
+
+	k := sort.SearchInts(indexes, i)
+	if k < len(indexes) && indexes[k] == i {
+		return
+	}
+
+	indexes = append(indexes, 0)
+	copy(indexes[k+1:], indexes[k:])
+	indexes[k] = i
+	*list = newPostingList(indexes)
+}
+
+// Remove index from list.
+func (list *postingList) Remove(i int) {
+	indexes := list.Indexes()
+
+	k := sort.SearchInts(indexes, i)
+	if k == len(indexes) || indexes[k] != i {
+		return
+	}
+
+	*list = newPostingList(append(indexes[:k], indexes[k+1:]...))
+}
+
+// Filter keep only indexes satisfying keep.
+func (list *postingList) Filter(keep func(int) bool) {
+	var filtered postingList
+
+	list.Each(func(i int) {
+		if keep(i) {
+			filtered.Append(i)
+		}
+	})
+
+	filtered.data = append([]byte(nil), filtered.data...)
+	filtered.skips = append([]postingSkip(nil), filtered.skips...)
+	*list = filtered
+}
+
+// Call fn with position and index of every posting of packed list contained
+// in ascending ids until fn return false. Before every id the list is moved
+// to the farthest block starting before it, so blocks without ids are not
+// decoded. Indexes and skip pointers are checked to be less than limit and
+// inside data, so crafted list is never read out of them.
+func seekPostings(
+	data []byte, skips int, skip func(k int) postingSkip, ids []int, limit int, fn func(position, i int) bool,
+) {
+	// Offset of not decoded data, number of decoded postings and the last
+	// of them, number of passed skip pointers.
+	offset, next, last, k := 0, 0, 0, 0
+
+	for _, id := range ids {
+		for k < skips && int(skip(k).last) < id {
+			k++
+		}
+
+		if k > 0 {
+			s := skip(k - 1)
+			if int(s.last) >= limit || int(s.offset) > len(data) {
+				return
+			}
+
+			if int(s.offset) > offset {
+				offset, next, last = int(s.offset), k*skipInterval, int(s.last)
+			}
+		}
+
+		for (next == 0 || last < id) && offset < len(data) {
+			delta, n := binary.Uvarint(data[offset:])
+			if n <= 0 || delta >= uint64(limit-last) {
+				return
+			}
+
+			offset += n
+			last += int(delta)
+			next++
+		}
+
+		if next > 0 && last == id && !fn(next-1, id) {
+			return
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package muzzy
+
+import (
+	"container/heap"
+	"context"
+	"sort"
+)
+
+// postingSource is a storage of posting lists.
+type postingSource interface {
+	// Call fn for every not removed string containing n-gram until fn
+	// return false. Number of occurrences of n-gram in the string is passed
+	// as tf, if index has BM25 ranking.
+	eachPosting(ngram string, fn func(i, tf int) bool)
+	// Call fn as eachPosting only for strings of ascending ids. Postings
+	// between them are skipped when possible.
+	eachPostingOf(ngram string, ids []int, fn func(i, tf int) bool)
+	// Number of not removed strings containing n-gram.
+	frequency(ngram string) int
+	// Number of strings, including removed ones.
+	total() int
+	// Number of not removed strings.
+	liveTotal() int
+	// Number of n-grams in i-th string.
+	size(i int) int
+	// IDF norm of the i-th string, if index has IDF model.
+	norm(i int) float64
+	// Average size of not removed strings, if index has BM25 ranking.
+	avgSize() float64
+	// The i-th string.
+	text(i int) string
+	// Payload of the i-th string.
+	payload(i int) interface{}
+}
+
+// query is a search of the n-grams set in posting lists.
+type query struct {
+	*indexOptions
+	source     postingSource
+	ngrams     []string
+	normalized string
+	// Optional context checked while posting lists are read. Search is
+	// stopped on cancellation and err is set.
+	ctx context.Context
+	err error
+	// Optional dense accumulator of MaxScore search reused between queries.
+	// It is zero between uses.
+	accumulator *[]float64
+	// Stop-grams excluded from ngrams.
+	stop []string
+	// IDF norm of the query, if index has IDF model.
+	queryNorm float64
+	// Average size of strings, if index has BM25 ranking.
+	avgSize  float64
+	prepared bool
+}
+
+// Number of postings read between checks of context.
+const postingsPerCheck = 4096
+
+// TopK return up to k hits with maximal score.
+func (q *query) TopK(k int) []Hit {
+	if hits, ok := q.maxScoreTopK(k); ok {
+		return hits
+	}
+
+	return q.rank(q.count(), k)
+}
+
+// Threshold return all hits with score great or equal to threshold.
+func (q *query) Threshold(threshold float64) []Hit {
+	counters := q.count()
+
+	minCommon := q.minCommon(threshold)
+	if minCommon < 0 {
+		return nil
+	}
+
+	var hits []Hit
+
+	for i, count := range counters {
+		if count < float64(minCommon) {
+			continue
+		}
+
+		if hit := q.hit(i, count); hit.Score >= threshold {
+			hits = append(hits, hit)
+		}
+	}
+
+	sortHits(hits)
+
+	return hits
+}
+
+// Scores return score of every string sharing n-grams with the query without
+// building of hits.
+func (q *query) Scores() map[int]float64 {
+	scores := q.count()
+
+	for i, count := range scores {
+		scores[i] = q.score(i, count)
+	}
+
+	return scores
+}
+
+func (q *query) rank(counters map[int]float64, k int) []Hit {
+	top := make(hitHeap, 0, min(k, len(counters)))
+
+	for i, count := range counters {
+		top.Offer(Hit{Index: i, Score: q.score(i, count)}, k)
+	}
+
+	return q.fill(top)
+}
+
+// Sort hits and fill them by strings data.
+func (q *query) fill(top []Hit) []Hit {
+	sortHits(top)
+
+	for j := range top {
+		top[j].String = q.source.text(top[j].Index)
+		top[j].Exact = q.normalizeString(top[j].String) == q.normalized
+		top[j].Payload = q.source.payload(top[j].Index)
+	}
+
+	return top
+}
+
+// Count n-grams shared by every indexed string with the query. With IDF model
+// n-grams are counted with their weights.
+func (q *query) count() map[int]float64 {
+	counters := map[int]float64{}
+
+	q.prepare()
+
+	read := 0
+
+	for _, ngram := range q.ngrams {
+		if !q.alive() {
+			break
+		}
+
+		weight := q.weight(ngram)
+
+		q.source.eachPosting(ngram, func(i, tf int) bool {
+			counters[i] += q.postingWeight(weight, i, tf)
+			read++
+
+			return read%postingsPerCheck != 0 || q.alive()
+		})
+	}
+
+	return counters
+}
+
+// Prepare query once: exclude stop-grams and calculate IDF norm.
+func (q *query) prepare() {
+	if q.prepared {
+		return
+	}
+
+	q.prepared = true
+	q.prune()
+
+	// Weights are summed in the same order everywhere, so scores of equal
+	// strings are equal. Heavy n-grams go first as MaxScore read them.
+	if q.idf != nil || q.bm25 != nil {
+		weights := make(map[string]float64, len(q.ngrams))
+		for _, ngram := range q.ngrams {
+			weights[ngram] = q.weight(ngram)
+		}
+
+		q.ngrams = append([]string(nil), q.ngrams...)
+		sort.Slice(q.ngrams, func(i, j int) bool {
+			a, b := q.ngrams[i], q.ngrams[j]
+			if weights[a] != weights[b] {
+				return weights[a] > weights[b]
+			}
+
+			return a < b
+		})
+	}
+
+	switch {
+	case q.bm25 != nil:
+		q.avgSize = q.source.avgSize()
+	case q.idf != nil:
+		q.queryNorm = q.idf.norm(q.ngrams)
+	}
+}
+
+// Exclude stop-grams from the query. If every n-gram is a stop-gram, none of
+// them is excluded.
+func (q *query) prune() {
+	if q.maxFrequency <= 0 {
+		return
+	}
+
+	limit := q.maxFrequency * float64(q.source.liveTotal())
+	ngrams := make([]string, 0, len(q.ngrams))
+
+	for _, ngram := range q.ngrams {
+		if float64(q.source.frequency(ngram)) > limit {
+			q.stop = append(q.stop, ngram)
+		} else {
+			ngrams = append(ngrams, ngram)
+		}
+	}
+
+	if len(ngrams) == 0 {
+		q.stop = nil
+
+		return
+	}
+
+	q.ngrams = ngrams
+}
+
+// Weight of n-gram of the query: BM25 IDF, squared IDF with IDF model or 1.
+func (q *query) weight(ngram string) float64 {
+	switch {
+	case q.bm25 != nil:
+		return q.bm25.IDF(q.source.frequency(ngram), q.source.liveTotal())
+	case q.idf != nil:
+		idf := q.idf.IDF(ngram)
+
+		return idf * idf
+	}
+
+	return 1
+}
+
+// Weight of n-gram of the query occurred tf times in the i-th string. Only
+// BM25 weight depend on the string.
+func (q *query) postingWeight(weight float64, i, tf int) float64 {
+	if q.bm25 == nil {
+		return weight
+	}
+
+	return q.bm25.Weight(weight, tf, q.source.size(i), q.avgSize)
+}
+
+// Score of the i-th string sharing n-grams with total weight count.
+func (q *query) score(i int, count float64) float64 {
+	switch {
+	case q.bm25 != nil:
+		return count
+	case q.idf == nil:
+		return q.coefficient(int(count), len(q.ngrams), q.source.size(i))
+	}
+
+	if norm := q.queryNorm * q.source.norm(i); norm > 0 {
+		return count / norm
+	}
+
+	return 0
+}
+
+// Return hits or error of cancelled query.
+func (q *query) result(hits []Hit) ([]Hit, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+
+	return hits, nil
+}
+
+// Check context of the query.
+func (q *query) alive() bool {
+	if q.ctx != nil && q.err == nil {
+		q.err = q.ctx.Err()
+	}
+
+	return q.err == nil
+}
+
+func (q *query) hit(i int, count float64) Hit {
+	s := q.source.text(i)
+
+	return Hit{
+		Index:   i,
+		String:  s,
+		Score:   q.score(i, count),
+		Exact:   q.normalizeString(s) == q.normalized,
+		Payload: q.source.payload(i),
+	}
+}
+
+// The coefficient of the query with n n-grams and a string sharing common
+// n-grams is maximal when the string contains no other n-grams. So minimal
+// number of common n-grams is the least common, that reach threshold in that
+// case. Return -1 if threshold is unreachable. Weighted counts are not
+// bounded, so with IDF model only threshold above 1 is checked and with BM25
+// nothing is checked.
+func (q *query) minCommon(threshold float64) int {
+	n := len(q.ngrams)
+
+	if q.bm25 != nil {
+		return 0
+	}
+
+	if q.idf != nil {
+		if threshold > 1 {
+			return -1
+		}
+
+		return 0
+	}
+
+	for common := 1; common <= n; common++ {
+		if q.coefficient(common, n, common) >= threshold {
+			return common
+		}
+	}
+
+	return -1
+}
+
+func sortHits(hits []Hit) {
+	sort.Slice(hits, func(i, j int) bool {
+		return better(hits[i], hits[j])
+	})
+}
+
+func better(a, b Hit) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+
+	return a.Index < b.Index
+}
+
+// hitHeap keep k best hits with the worst one on top.
+type hitHeap []Hit
+
+func (h hitHeap) Len() int            { return len(h) }
+func (h hitHeap) Less(i, j int) bool  { return better(h[j], h[i]) }
+func (h hitHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hitHeap) Push(x interface{}) { *h = append(*h, x.(Hit)) }
+
+func (h *hitHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return x
+}
+
+// Offer hit to the heap of up to k hits.
+func (h *hitHeap) Offer(hit Hit, k int) {
+	switch {
+	case len(*h) < k:
+		heap.Push(h, hit)
+	case better(hit, (*h)[0]):
+		(*h)[0] = hit
+		heap.Fix(h, 0)
+	}
+}
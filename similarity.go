@@ -1,6 +1,7 @@
 package muzzy
 
 import (
+	"context"
 	"math"
 )
 
@@ -23,12 +24,20 @@ const (
 // strings are the same (Jaro-Winkler algorithm may return 1 even if strings
 // are different).
 func Similarity(s1, s2 string, algo similarityAlgorithm, threshold float64) float64 {
+	d, _ := similarity(context.Background(), s1, s2, algo, threshold)
+
+	return d
+}
+
+// Similarity, that stop distance calculation on context cancellation and
+// return context error.
+func similarity(ctx context.Context, s1, s2 string, algo similarityAlgorithm, threshold float64) (float64, error) {
 	if s1 == "" {
 		if s2 == "" {
-			return 1
+			return 1, nil
 		}
 
-		return 0
+		return 0, nil
 	}
 
 	var d float64
@@ -38,18 +47,17 @@ func Similarity(s1, s2 string, algo similarityAlgorithm, threshold float64) floa
 		max := math.Max(float64(len(s1)), float64(len(s2)))
 		bound := int(math.Floor((1 - threshold) * max))
 
-		var distance int
-		if algo == Levenshtein {
-			distance = LevenshteinDistance(s1, s2, bound)
-		} else {
-			distance = DamerauDistance(s1, s2, bound)
+		calc := newLevenshteinCalculator
+		if algo == DamerauLevenshtein {
+			calc = newDamerauCalculator
 		}
 
-		if distance < 0 {
-			return 0
+		n, err := distance(ctx, s1, s2, bound, calc)
+		if n < 0 {
+			return 0, err
 		}
 
-		d = 1 - float64(distance)/max
+		d = 1 - float64(n)/max
 
 	case Jaro:
 		d = JaroSimilarity(s1, s2)
@@ -62,8 +70,8 @@ func Similarity(s1, s2 string, algo similarityAlgorithm, threshold float64) floa
 	}
 
 	if d < threshold {
-		return 0
+		return 0, nil
 	}
 
-	return d
+	return d, nil
 }
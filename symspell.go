@@ -0,0 +1,177 @@
+package muzzy
+
+import (
+	"sort"
+	"sync"
+)
+
+// SymSpell index to suggest spelling corrections by symmetric deletions
+//
+// Every word is indexed by strings made from it by deletion of up to
+// maxDistance runes. Two strings within DamerauDistance k share a string made
+// by deletion of up to k runes from each of them, so candidates of a query
+// are words sharing deletions with it. Candidates are verified by
+// DamerauDistance with bound. Number of deletions grows fast with length of
+// word, so small maxDistance (1 or 2) is the best choice. SymSpell is safe
+// for concurrent use.
+type SymSpell struct {
+	mu          sync.RWMutex
+	maxDistance int
+	words       []string
+	frequencies []int
+	ids         map[string]int
+	deletes     map[string][]int
+}
+
+// Suggestion is a SymSpell lookup result.
+type Suggestion struct {
+	// Index of the word in index.
+	Index int
+	// String is the word.
+	String string
+	// Distance from the query to the word.
+	Distance int
+	// Frequency of the word.
+	Frequency int
+}
+
+// NewSymSpell is a constructor.
+func NewSymSpell(maxDistance int) *SymSpell {
+	if maxDistance < 0 {
+		maxDistance = 0
+	}
+
+	return &SymSpell{
+		maxDistance: maxDistance,
+		ids:         map[string]int{},
+		deletes:     map[string][]int{},
+	}
+}
+
+// Add word with frequency to index and return index of the word
+//
+// Frequency of word already contained in index is increased.
+func (index *SymSpell) Add(word string, frequency int) int {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	if id, ok := index.ids[word]; ok {
+		index.frequencies[id] += frequency
+
+		return id
+	}
+
+	id := len(index.words)
+	index.ids[word] = id
+	index.words = append(index.words, word)
+	index.frequencies = append(index.frequencies, frequency)
+
+	for deletion := range deletions(word, index.maxDistance) {
+		index.deletes[deletion] = append(index.deletes[deletion], id)
+	}
+
+	return id
+}
+
+// Len return number of words in index.
+func (index *SymSpell) Len() int {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	return len(index.words)
+}
+
+// Frequency of the word or 0, if index does not contain it.
+func (index *SymSpell) Frequency(word string) int {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	if id, ok := index.ids[word]; ok {
+		return index.frequencies[id]
+	}
+
+	return 0
+}
+
+// Lookup return words within DamerauDistance k from s
+//
+// Distance k is limited by maxDistance of index. Suggestions are sorted by
+// distance, then by frequency in descending order, then by index.
+func (index *SymSpell) Lookup(s string, k int) []Suggestion {
+	if k < 0 {
+		return nil
+	}
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	if k > index.maxDistance {
+		k = index.maxDistance
+	}
+
+	var suggestions []Suggestion
+
+	checked := map[int]bool{}
+
+	for deletion := range deletions(s, k) {
+		for _, id := range index.deletes[deletion] {
+			if checked[id] {
+				continue
+			}
+
+			checked[id] = true
+
+			if d := DamerauDistance(s, index.words[id], k); d >= 0 {
+				suggestions = append(suggestions, Suggestion{
+					Index:     id,
+					String:    index.words[id],
+					Distance:  d,
+					Frequency: index.frequencies[id],
+				})
+			}
+		}
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+
+		switch {
+		case a.Distance != b.Distance:
+			return a.Distance < b.Distance
+		case a.Frequency != b.Frequency:
+			return a.Frequency > b.Frequency
+		}
+
+		return a.Index < b.Index
+	})
+
+	return suggestions
+}
+
+// Strings made from s by deletion of up to k runes, including s.
+func deletions(s string, k int) map[string]struct{} {
+	result := map[string]struct{}{s: {}}
+	level := [][]rune{[]rune(s)}
+
+	for d := 0; d < k; d++ {
+		var next [][]rune
+
+		for _, rs := range level {
+			for i := range rs {
+				deleted := make([]rune, 0, len(rs)-1)
+				deleted = append(deleted, rs[:i]...)
+				deleted = append(deleted, rs[i+1:]...)
+
+				key := string(deleted)
+				if _, ok := result[key]; !ok {
+					result[key] = struct{}{}
+					next = append(next, deleted)
+				}
+			}
+		}
+
+		level = next
+	}
+
+	return result
+}
@@ -0,0 +1,111 @@
+package muzzy_test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vporoshok/muzzy"
+)
+
+func TestSymSpell(t *testing.T) {
+	index := muzzy.NewSymSpell(2)
+	assert.Equal(t, 0, index.Add("milk", 10))
+	assert.Equal(t, 1, index.Add("silk", 3))
+	assert.Equal(t, 2, index.Add("mile", 7))
+	assert.Equal(t, 0, index.Add("milk", 5))
+	assert.Equal(t, 3, index.Add("mix", 1))
+
+	assert.Equal(t, 4, index.Len())
+	assert.Equal(t, 15, index.Frequency("milk"))
+	assert.Equal(t, 0, index.Frequency("happiness"))
+
+	assert.Equal(t, []muzzy.Suggestion{
+		{Index: 0, String: "milk", Distance: 1, Frequency: 15},
+		{Index: 2, String: "mile", Distance: 2, Frequency: 7},
+		{Index: 1, String: "silk", Distance: 2, Frequency: 3},
+		{Index: 3, String: "mix", Distance: 2, Frequency: 1},
+	}, index.Lookup("mikl", 2))
+
+	assert.Equal(t, []muzzy.Suggestion{
+		{Index: 0, String: "milk", Distance: 0, Frequency: 15},
+		{Index: 2, String: "mile", Distance: 1, Frequency: 7},
+		{Index: 1, String: "silk", Distance: 1, Frequency: 3},
+	}, index.Lookup("milk", 1))
+
+	assert.Len(t, index.Lookup("milk", 5), 4)
+	assert.Empty(t, index.Lookup("milk", -1))
+	assert.Empty(t, index.Lookup("happiness", 2))
+}
+
+func TestSymSpellCorpus(t *testing.T) {
+	index := muzzy.NewSymSpell(2)
+	frequencies := map[string]int{}
+
+	var words []string
+
+	for _, word := range strings.Fields(readCorpus(t)) {
+		word = strings.ToLower(strings.Trim(word, `.,;:!?"()«»—-`))
+		if word == "" {
+			continue
+		}
+
+		if frequencies[word] == 0 {
+			words = append(words, word)
+		}
+
+		frequencies[word]++
+		index.Add(word, 1)
+	}
+
+	queries := []string{"чичиков", "чичков", "манилв", "он", "оно", "x", ""}
+	for i := 0; i < len(words); i += 499 {
+		queries = append(queries, words[i])
+	}
+
+	for _, query := range queries {
+		for k := 0; k <= 2; k++ {
+			var expected []muzzy.Suggestion
+
+			for i, word := range words {
+				if d := muzzy.DamerauDistance(query, word, k); d >= 0 {
+					expected = append(expected, muzzy.Suggestion{
+						Index: i, String: word, Distance: d, Frequency: frequencies[word],
+					})
+				}
+			}
+
+			sort.SliceStable(expected, func(i, j int) bool {
+				if expected[i].Distance != expected[j].Distance {
+					return expected[i].Distance < expected[j].Distance
+				}
+
+				return expected[i].Frequency > expected[j].Frequency
+			})
+
+			actual := index.Lookup(query, k)
+			if len(expected) == 0 {
+				assert.Empty(t, actual, "%q within %d", query, k)
+			} else {
+				assert.Equal(t, expected, actual, "%q within %d", query, k)
+			}
+		}
+	}
+}
+
+func BenchmarkSymSpell(b *testing.B) {
+	words := corpusWords(b)
+	index := muzzy.NewSymSpell(2)
+
+	for _, word := range words {
+		index.Add(word, 1)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		index.Lookup(words[i%len(words)], 2)
+	}
+}
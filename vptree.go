@@ -0,0 +1,279 @@
+package muzzy
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// Metric is a distance between strings.
+type Metric func(a, b string) float64
+
+// DistanceMetric convert Distance to Metric.
+func DistanceMetric(distance Distance) Metric {
+	return func(a, b string) float64 {
+		return float64(distance(a, b, -1))
+	}
+}
+
+// SimilarityMetric is 1-Similarity of two strings with given algorithm
+//
+// Normalized distances are not metrics in general: triangle inequality may
+// be broken, so VPTree may miss some strings with them.
+func SimilarityMetric(algo similarityAlgorithm) Metric {
+	return func(a, b string) float64 {
+		return 1 - Similarity(a, b, algo, 0)
+	}
+}
+
+// SplitterMetric is a square root of 1-Similarity of splitter
+//
+// With Otsuka-Ochiai coefficient, as NGramSplitter similarity is, it is a
+// chord distance between normalized n-gram vectors, so it is a metric, unlike
+// 1-Similarity. Order of strings by distance is the same as by similarity.
+// Distance between equal strings is 0, and distance to the string without
+// n-grams is 1.
+func SplitterMetric(splitter Splitter) Metric {
+	return func(a, b string) float64 {
+		if a == b {
+			return 0
+		}
+
+		return math.Sqrt(math.Max(0, 1-splitter.Similarity(a, b)))
+	}
+}
+
+// Relative error of distances, so rounding never prune string at the
+// boundary.
+const vpEpsilon = 1e-9
+
+// VPTree index to search strings by arbitrary metric
+//
+// Every node splits strings of its subtree by median distance to the vantage
+// string: nearer strings go to inside subtree, the others go to outside one.
+// By triangle inequality subtrees farther than search radius are skipped.
+// Tree is built once from the list of strings. VPTree is safe for concurrent
+// use.
+type VPTree struct {
+	metric  Metric
+	strings []string
+	nodes   []vpNode
+}
+
+type vpNode struct {
+	// Index of vantage string.
+	index int
+	// Median distance to vantage string.
+	radius float64
+	// Inside and outside subtrees or -1.
+	inside, outside int
+}
+
+// VPHit is a VPTree search result.
+type VPHit struct {
+	// Index of the string in tree.
+	Index int
+	// String is the indexed string.
+	String string
+	// Distance from the query to the string.
+	Distance float64
+}
+
+// NewVPTree build tree of strings
+//
+// Vantage strings are chosen deterministically, so trees of the same strings
+// are equal.
+func NewVPTree(metric Metric, ss []string) *VPTree {
+	tree := &VPTree{
+		metric:  metric,
+		strings: append([]string(nil), ss...),
+		nodes:   make([]vpNode, 0, len(ss)),
+	}
+
+	indexes := make([]int, len(ss))
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	tree.build(indexes, make([]float64, len(ss)))
+
+	return tree
+}
+
+// Build subtree of strings with given indexes and return its node or -1.
+// The first string is the vantage one.
+func (tree *VPTree) build(indexes []int, distances []float64) int {
+	if len(indexes) == 0 {
+		return -1
+	}
+
+	id := len(tree.nodes)
+	tree.nodes = append(tree.nodes, vpNode{index: indexes[0], inside: -1, outside: -1})
+
+	rest := indexes[1:]
+	if len(rest) == 0 {
+		return id
+	}
+
+	vantage := tree.strings[indexes[0]]
+	for _, i := range rest {
+		distances[i] = tree.metric(vantage, tree.strings[i])
+	}
+
+	sort.Slice(rest, func(a, b int) bool {
+		if distances[rest[a]] != distances[rest[b]] {
+			return distances[rest[a]] < distances[rest[b]]
+		}
+
+		return rest[a] < rest[b]
+	})
+
+	median := len(rest) / 2
+	radius := distances[rest[median]]
+	inside := tree.build(rest[:median+1], distances)
+	outside := tree.build(rest[median+1:], distances)
+
+	node := &tree.nodes[id]
+	node.radius, node.inside, node.outside = radius, inside, outside
+
+	return id
+}
+
+// Len return number of strings in tree.
+func (tree *VPTree) Len() int {
+	return len(tree.strings)
+}
+
+// Get string by index.
+func (tree *VPTree) Get(i int) string {
+	if i < 0 || i >= len(tree.strings) {
+		return ""
+	}
+
+	return tree.strings[i]
+}
+
+// SearchRadius return all strings within distance r from s
+//
+// Hits are sorted by distance and index.
+func (tree *VPTree) SearchRadius(s string, r float64) []VPHit {
+	if r < 0 {
+		return nil
+	}
+
+	var hits []VPHit
+
+	tree.walk(s, func() float64 { return r }, func(hit VPHit) {
+		hits = append(hits, hit)
+	})
+
+	sortVPHits(hits)
+
+	return hits
+}
+
+// SearchNearest return up to k strings nearest to s
+//
+// Hits are sorted by distance and index.
+func (tree *VPTree) SearchNearest(s string, k int) []VPHit {
+	if k <= 0 {
+		return nil
+	}
+
+	top := make(vpHitHeap, 0, min(k, len(tree.strings)))
+
+	radius := func() float64 {
+		if len(top) < k {
+			return math.Inf(1)
+		}
+
+		return top[0].Distance
+	}
+
+	tree.walk(s, radius, func(hit VPHit) {
+		switch {
+		case len(top) < k:
+			heap.Push(&top, hit)
+		case vpBetter(hit, top[0]):
+			top[0] = hit
+			heap.Fix(&top, 0)
+		}
+	})
+
+	sortVPHits(top)
+
+	return top
+}
+
+// Walk tree and call found for every string within radius from s. Radius may
+// decrease during walk. Nearer subtree is visited first.
+func (tree *VPTree) walk(s string, radius func() float64, found func(VPHit)) {
+	if len(tree.nodes) == 0 {
+		return
+	}
+
+	stack := []int{0}
+
+	for len(stack) > 0 {
+		node := tree.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+
+		d := tree.metric(s, tree.strings[node.index])
+		if d <= radius() {
+			found(VPHit{Index: node.index, String: tree.strings[node.index], Distance: d})
+		}
+
+		r := radius() + vpEpsilon*(1+d)
+		insideNeeded := node.inside >= 0 && d-r <= node.radius
+		outsideNeeded := node.outside >= 0 && d+r >= node.radius
+
+		// The last pushed subtree is visited first.
+		if d < node.radius {
+			if outsideNeeded {
+				stack = append(stack, node.outside)
+			}
+
+			if insideNeeded {
+				stack = append(stack, node.inside)
+			}
+		} else {
+			if insideNeeded {
+				stack = append(stack, node.inside)
+			}
+
+			if outsideNeeded {
+				stack = append(stack, node.outside)
+			}
+		}
+	}
+}
+
+func sortVPHits(hits []VPHit) {
+	sort.Slice(hits, func(i, j int) bool {
+		return vpBetter(hits[i], hits[j])
+	})
+}
+
+func vpBetter(a, b VPHit) bool {
+	if a.Distance != b.Distance {
+		return a.Distance < b.Distance
+	}
+
+	return a.Index < b.Index
+}
+
+// vpHitHeap keep nearest hits with the farthest one on top.
+type vpHitHeap []VPHit
+
+func (h vpHitHeap) Len() int            { return len(h) }
+func (h vpHitHeap) Less(i, j int) bool  { return vpBetter(h[j], h[i]) }
+func (h vpHitHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *vpHitHeap) Push(x interface{}) { *h = append(*h, x.(VPHit)) }
+
+func (h *vpHitHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return x
+}
@@ -0,0 +1,113 @@
+package muzzy_test
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vporoshok/muzzy"
+)
+
+// Search all strings by full scan.
+func scanMetric(ss []string, metric muzzy.Metric, s string) []muzzy.VPHit {
+	hits := make([]muzzy.VPHit, len(ss))
+	for i, x := range ss {
+		hits[i] = muzzy.VPHit{Index: i, String: x, Distance: metric(s, x)}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Distance < hits[j].Distance
+	})
+
+	return hits
+}
+
+func TestVPTree(t *testing.T) {
+	lines := corpusLines(t, 100)
+
+	metrics := map[string]muzzy.Metric{
+		"Levenshtein": muzzy.DistanceMetric(muzzy.LevenshteinDistance),
+		"NGram":       muzzy.SplitterMetric(muzzy.NGramSplitter(3, true)),
+	}
+
+	queries := []string{"Чичиков", "Николай Васильевич Гоголь", ""}
+	for i := 0; i < len(lines); i += 40 {
+		queries = append(queries, lines[i], strings.ToUpper(lines[i]))
+	}
+
+	for name, metric := range metrics {
+		tree := muzzy.NewVPTree(metric, lines)
+		require.Equal(t, len(lines), tree.Len())
+		assert.Equal(t, lines[1], tree.Get(1))
+		assert.Equal(t, "", tree.Get(len(lines)))
+
+		for _, query := range queries {
+			all := scanMetric(lines, metric, query)
+
+			for _, k := range [...]int{1, 5, 20} {
+				assert.Equal(t, all[:k], tree.SearchNearest(query, k), "%s %q nearest %d", name, query, k)
+			}
+
+			for _, r := range [...]float64{0, all[3].Distance, all[30].Distance} {
+				expected := all[:sort.Search(len(all), func(i int) bool { return all[i].Distance > r })]
+				if len(expected) == 0 {
+					assert.Empty(t, tree.SearchRadius(query, r), "%s %q within %g", name, query, r)
+				} else {
+					assert.Equal(t, expected, tree.SearchRadius(query, r), "%s %q within %g", name, query, r)
+				}
+			}
+		}
+
+		assert.Empty(t, tree.SearchNearest(lines[0], 0))
+		assert.Empty(t, tree.SearchRadius(lines[0], -1))
+	}
+
+	assert.Empty(t, muzzy.NewVPTree(metrics["NGram"], nil).SearchNearest("Чичиков", 1))
+}
+
+func TestSimilarityMetrics(t *testing.T) {
+	metric := muzzy.SimilarityMetric(muzzy.Levenshtein)
+	assert.InDelta(t, 0.25, metric("milk", "silk"), 1e-9)
+	assert.InDelta(t, 0, metric("milk", "milk"), 1e-9)
+
+	splitter := muzzy.NGramSplitter(3, true)
+	metric = muzzy.SplitterMetric(splitter)
+	assert.InDelta(t, math.Sqrt(1-splitter.Similarity("milk", "silk")), metric("milk", "silk"), 1e-9)
+	assert.Equal(t, 0.0, metric("", ""))
+	assert.Equal(t, 1.0, metric("", "milk"))
+
+	tree := muzzy.NewVPTree(muzzy.SimilarityMetric(muzzy.JaroWinkler), []string{"milk", "silk", "happiness", "princess"})
+
+	hits := tree.SearchNearest("mikl", 1)
+	if assert.Len(t, hits, 1) {
+		assert.Equal(t, "milk", hits[0].String)
+	}
+}
+
+func BenchmarkVPTree(b *testing.B) {
+	lines := corpusLines(b, 500)
+	metric := muzzy.DistanceMetric(muzzy.LevenshteinDistance)
+	tree := muzzy.NewVPTree(metric, lines)
+
+	// Lines with a typo: the first rune is dropped.
+	queries := make([]string, len(lines))
+	for i, line := range lines {
+		queries[i] = string([]rune(line)[1:])
+	}
+
+	b.Run("VPTree", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			tree.SearchNearest(queries[i%len(queries)], 1)
+		}
+	})
+
+	b.Run("Scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			scanMetric(lines, metric, queries[i%len(queries)])
+		}
+	})
+}